@@ -2,7 +2,9 @@ package command
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 
 	"github.com/micro/cli/v2"
 	"github.com/owncloud/ocis-ocs/pkg/config"
@@ -20,7 +22,7 @@ func Health(cfg *config.Config) *cli.Command {
 
 			resp, err := http.Get(
 				fmt.Sprintf(
-					"http://%s/healthz",
+					"http://%s/readyz",
 					cfg.Debug.Addr,
 				),
 			)
@@ -33,6 +35,13 @@ func Health(cfg *config.Config) *cli.Command {
 
 			defer resp.Body.Close()
 
+			// print the per dependency report of the ready check
+			if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+				logger.Error().
+					Err(err).
+					Msg("Failed to print health report")
+			}
+
 			if resp.StatusCode != 200 {
 				logger.Fatal().
 					Int("code", resp.StatusCode).
@@ -1,10 +1,34 @@
 package config
 
+import (
+	"time"
+
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+)
+
 // Log defines the available logging configuration.
 type Log struct {
 	Level  string
 	Pretty bool
 	Color  bool
+	Access AccessLog
+}
+
+// AccessLog defines the available access log configuration.
+type AccessLog struct {
+	// Level of the access log entries, disabled turns the access log off
+	Level string
+	// Routes overrides the level and sampling by chi route pattern as logged in the route field, including the
+	// http root, e.g. /ocs/v{version:(1|2)}.php/cloud/capabilities/
+	Routes map[string]AccessLogRoute
+}
+
+// AccessLogRoute defines the access log configuration of a single route.
+type AccessLogRoute struct {
+	// Level of the access log entries of the route, defaults to the access log level
+	Level string
+	// Sample only logs every nth request of the route
+	Sample uint32
 }
 
 // Debug defines the available debug configuration.
@@ -36,14 +60,86 @@ type TokenManager struct {
 	JWTSecret string
 }
 
+// Reva defines all available REVA configuration.
+type Reva struct {
+	Address       string
+	HomeNamespace string
+}
+
+// Sharing defines the available sharing configuration.
+type Sharing struct {
+	PublicURL string
+}
+
+// Authorization defines the available authorization configuration.
+type Authorization struct {
+	// AdminPermission is the id of the settings permission that grants full access to the provisioning API
+	AdminPermission string
+}
+
+// Authentication defines the available authentication configuration.
+type Authentication struct {
+	// BasicAuth accepts http basic credentials in addition to access tokens
+	BasicAuth bool
+	OIDC      OIDC
+}
+
+// OIDC defines the available openid connect bearer token configuration.
+type OIDC struct {
+	// Issuer of the accepted bearer tokens, bearer tokens are only accepted if it is set
+	Issuer string
+	// Audience the bearer tokens must be issued for
+	Audience string
+	// JWKSURL to load the signing keys of the issuer from
+	JWKSURL string
+	// JWKSFile to load the signing keys of the issuer from, instead of the url
+	JWKSFile string
+	// JWKSRefresh is the interval the signing keys are reloaded in
+	JWKSRefresh time.Duration
+	// UserClaim is the claim of bearer tokens that identifies the account
+	UserClaim string
+	// AccountField is the account field the user claim is compared to: id, on_premises_sam_account_name,
+	// preferred_name or mail
+	AccountField string
+}
+
+// Quota defines the available quota configuration.
+type Quota struct {
+	// Default is the quota definition of users without a quota and without a group default
+	Default string
+	// Groups maps group ids to the default quota definition of their members
+	Groups map[string]string
+}
+
+// PasswordPolicy defines the rules for passwords set through the provisioning API. Zero values disable a rule.
+type PasswordPolicy struct {
+	MinLength    int
+	MaxLength    int
+	MinLowerCase int
+	MinUpperCase int
+	MinDigits    int
+	MinSpecial   int
+	// DisallowedFile is a local file with one disallowed password per line
+	DisallowedFile string
+	// DisallowUsername rejects passwords that contain the username, ignoring case
+	DisallowUsername bool
+}
+
 // Config combines all available configuration parts.
 type Config struct {
-	File         string
-	Log          Log
-	Debug        Debug
-	HTTP         HTTP
-	Tracing      Tracing
-	TokenManager TokenManager
+	File           string
+	Log            Log
+	Debug          Debug
+	HTTP           HTTP
+	Tracing        Tracing
+	TokenManager   TokenManager
+	Reva           Reva
+	Sharing        Sharing
+	Authentication Authentication
+	Authorization  Authorization
+	Quota          Quota
+	PasswordPolicy PasswordPolicy
+	Capabilities   data.Capabilities
 }
 
 // New initializes a new configuration with or without defaults.
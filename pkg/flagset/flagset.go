@@ -1,6 +1,8 @@
 package flagset
 
 import (
+	"time"
+
 	"github.com/micro/cli/v2"
 	"github.com/owncloud/ocis-ocs/pkg/config"
 )
@@ -55,6 +57,13 @@ func HealthWithConfig(cfg *config.Config) []cli.Flag {
 // ServerWithConfig applies cfg to the root flagset
 func ServerWithConfig(cfg *config.Config) []cli.Flag {
 	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "log-access-level",
+			Value:       "info",
+			Usage:       "Level of the access log entries, 'disabled' turns the access log off",
+			EnvVars:     []string{"OCS_LOG_ACCESS_LEVEL"},
+			Destination: &cfg.Log.Access.Level,
+		},
 		&cli.BoolFlag{
 			Name:        "tracing-enabled",
 			Value:       false,
@@ -145,5 +154,152 @@ func ServerWithConfig(cfg *config.Config) []cli.Flag {
 			EnvVars:     []string{"OCS_JWT_SECRET"},
 			Destination: &cfg.TokenManager.JWTSecret,
 		},
+		&cli.StringFlag{
+			Name:        "reva-gateway-addr",
+			Value:       "127.0.0.1:9142",
+			Usage:       "REVA Gateway Endpoint",
+			EnvVars:     []string{"OCS_REVA_GATEWAY_ADDR"},
+			Destination: &cfg.Reva.Address,
+		},
+		&cli.StringFlag{
+			Name:        "home-namespace",
+			Value:       "/home",
+			Usage:       "Namespace of the users home in the reva gateway, share paths are relative to it",
+			EnvVars:     []string{"OCS_HOME_NAMESPACE"},
+			Destination: &cfg.Reva.HomeNamespace,
+		},
+		&cli.StringFlag{
+			Name:        "public-url",
+			Value:       "https://localhost:9200",
+			Usage:       "Public URL of the web UI, used to build public link URLs",
+			EnvVars:     []string{"OCS_PUBLIC_URL"},
+			Destination: &cfg.Sharing.PublicURL,
+		},
+		&cli.StringFlag{
+			Name:        "default-quota",
+			Value:       "none",
+			Usage:       "Quota of users without an explicit or group default quota, e.g. '5 GB' or 'none'",
+			EnvVars:     []string{"OCS_DEFAULT_QUOTA"},
+			Destination: &cfg.Quota.Default,
+		},
+		&cli.IntFlag{
+			Name:        "password-min-length",
+			Value:       0,
+			Usage:       "Minimum number of characters of user passwords",
+			EnvVars:     []string{"OCS_PASSWORD_MIN_LENGTH"},
+			Destination: &cfg.PasswordPolicy.MinLength,
+		},
+		&cli.IntFlag{
+			Name:        "password-max-length",
+			Value:       0,
+			Usage:       "Maximum number of characters of user passwords, 0 for no limit",
+			EnvVars:     []string{"OCS_PASSWORD_MAX_LENGTH"},
+			Destination: &cfg.PasswordPolicy.MaxLength,
+		},
+		&cli.IntFlag{
+			Name:        "password-min-lowercase",
+			Value:       0,
+			Usage:       "Minimum number of lowercase letters in user passwords",
+			EnvVars:     []string{"OCS_PASSWORD_MIN_LOWERCASE"},
+			Destination: &cfg.PasswordPolicy.MinLowerCase,
+		},
+		&cli.IntFlag{
+			Name:        "password-min-uppercase",
+			Value:       0,
+			Usage:       "Minimum number of uppercase letters in user passwords",
+			EnvVars:     []string{"OCS_PASSWORD_MIN_UPPERCASE"},
+			Destination: &cfg.PasswordPolicy.MinUpperCase,
+		},
+		&cli.IntFlag{
+			Name:        "password-min-digits",
+			Value:       0,
+			Usage:       "Minimum number of digits in user passwords",
+			EnvVars:     []string{"OCS_PASSWORD_MIN_DIGITS"},
+			Destination: &cfg.PasswordPolicy.MinDigits,
+		},
+		&cli.IntFlag{
+			Name:        "password-min-special",
+			Value:       0,
+			Usage:       "Minimum number of special characters in user passwords",
+			EnvVars:     []string{"OCS_PASSWORD_MIN_SPECIAL"},
+			Destination: &cfg.PasswordPolicy.MinSpecial,
+		},
+		&cli.StringFlag{
+			Name:        "password-disallowed-file",
+			Value:       "",
+			Usage:       "Path to a file with one disallowed password per line",
+			EnvVars:     []string{"OCS_PASSWORD_DISALLOWED_FILE"},
+			Destination: &cfg.PasswordPolicy.DisallowedFile,
+		},
+		&cli.BoolFlag{
+			Name:        "password-disallow-username",
+			Value:       false,
+			Usage:       "Reject passwords that contain the username, ignoring case",
+			EnvVars:     []string{"OCS_PASSWORD_DISALLOW_USERNAME"},
+			Destination: &cfg.PasswordPolicy.DisallowUsername,
+		},
+		&cli.BoolFlag{
+			Name:        "enable-basic-auth",
+			Value:       false,
+			Usage:       "Accept http basic credentials, validated by the accounts service",
+			EnvVars:     []string{"OCS_ENABLE_BASIC_AUTH"},
+			Destination: &cfg.Authentication.BasicAuth,
+		},
+		&cli.StringFlag{
+			Name:        "oidc-issuer",
+			Value:       "",
+			Usage:       "Accept bearer tokens of this openid connect issuer",
+			EnvVars:     []string{"OCS_OIDC_ISSUER"},
+			Destination: &cfg.Authentication.OIDC.Issuer,
+		},
+		&cli.StringFlag{
+			Name:        "oidc-audience",
+			Value:       "",
+			Usage:       "Audience bearer tokens must be issued for",
+			EnvVars:     []string{"OCS_OIDC_AUDIENCE"},
+			Destination: &cfg.Authentication.OIDC.Audience,
+		},
+		&cli.StringFlag{
+			Name:        "oidc-jwks-url",
+			Value:       "",
+			Usage:       "URL of the signing keys of the openid connect issuer",
+			EnvVars:     []string{"OCS_OIDC_JWKS_URL"},
+			Destination: &cfg.Authentication.OIDC.JWKSURL,
+		},
+		&cli.StringFlag{
+			Name:        "oidc-jwks-file",
+			Value:       "",
+			Usage:       "Path to a file with the signing keys of the openid connect issuer, used instead of the url",
+			EnvVars:     []string{"OCS_OIDC_JWKS_FILE"},
+			Destination: &cfg.Authentication.OIDC.JWKSFile,
+		},
+		&cli.DurationFlag{
+			Name:        "oidc-jwks-refresh",
+			Value:       time.Hour,
+			Usage:       "Interval the signing keys of the openid connect issuer are reloaded in",
+			EnvVars:     []string{"OCS_OIDC_JWKS_REFRESH"},
+			Destination: &cfg.Authentication.OIDC.JWKSRefresh,
+		},
+		&cli.StringFlag{
+			Name:        "oidc-user-claim",
+			Value:       "preferred_username",
+			Usage:       "Claim of bearer tokens that identifies the account",
+			EnvVars:     []string{"OCS_OIDC_USER_CLAIM"},
+			Destination: &cfg.Authentication.OIDC.UserClaim,
+		},
+		&cli.StringFlag{
+			Name:        "oidc-account-field",
+			Value:       "on_premises_sam_account_name",
+			Usage:       "Account field the user claim is compared to: id, on_premises_sam_account_name, preferred_name or mail",
+			EnvVars:     []string{"OCS_OIDC_ACCOUNT_FIELD"},
+			Destination: &cfg.Authentication.OIDC.AccountField,
+		},
+		&cli.StringFlag{
+			Name:        "admin-permission",
+			Value:       "8e587774-d929-4215-910b-a317b1e80f73",
+			Usage:       "ID of the settings permission that grants full access to the provisioning API",
+			EnvVars:     []string{"OCS_ADMIN_PERMISSION"},
+			Destination: &cfg.Authorization.AdminPermission,
+		},
 	}
 }
@@ -1,5 +1,7 @@
 package metrics
 
+import "github.com/prometheus/client_golang/prometheus"
+
 var (
 	// Namespace defines the namespace for the defines metrics.
 	Namespace = "ocis"
@@ -8,25 +10,64 @@ var (
 	Subsystem = "ocs"
 )
 
+// requestLabels are the labels of the request metrics: the chi route pattern, the ocs api version, the response
+// format, the http status and the ocs status code
+var requestLabels = []string{"route", "version", "format", "status", "ocs_status"}
+
+// backendLabels are the labels of the backend call durations: the called micro service and its method
+var backendLabels = []string{"service", "method"}
+
 // Metrics defines the available metrics of this service.
 type Metrics struct {
-	// Counter  *prometheus.CounterVec
+	Requests        *prometheus.CounterVec
+	Duration        *prometheus.HistogramVec
+	BackendCalls    *prometheus.CounterVec
+	BackendDuration *prometheus.HistogramVec
 }
 
 // New initializes the available metrics.
 func New() *Metrics {
 	m := &Metrics{
-		// Counter: prometheus.NewCounterVec(prometheus.CounterOpts{
-		// 	Namespace: Namespace,
-		// 	Subsystem: Subsystem,
-		// 	Name:      "greet_total",
-		// 	Help:      "How many greeting requests processed",
-		// }, []string{}),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "requests_total",
+			Help:      "How many ocs requests processed",
+		}, requestLabels),
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "How long ocs requests took to process",
+			Buckets:   prometheus.DefBuckets,
+		}, requestLabels),
+		BackendCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "backend_calls_total",
+			Help:      "How many calls to backend services were made, by error code",
+		}, []string{"service", "method", "code"}),
+		BackendDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "backend_call_duration_seconds",
+			Help:      "How long calls to backend services took",
+			Buckets:   prometheus.DefBuckets,
+		}, backendLabels),
 	}
 
-	// prometheus.Register(
-	// 	m.Counter,
-	// )
+	prometheus.Register(
+		m.Requests,
+	)
+	prometheus.Register(
+		m.Duration,
+	)
+	prometheus.Register(
+		m.BackendCalls,
+	)
+	prometheus.Register(
+		m.BackendDuration,
+	)
 
 	return m
 }
@@ -1,13 +1,24 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/token"
 	"github.com/cs3org/reva/pkg/token/manager/jwt"
 	"github.com/cs3org/reva/pkg/user"
+	"github.com/go-chi/render"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"google.golang.org/grpc/metadata"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
 )
 
-// AccessToken middleware is used to set the user from an x-access-token to the context
+// AccessToken middleware is used to set the user from an x-access-token to the context.
+// It renders ocs errors, so it has to run after the version middleware.
 func AccessToken(opts ...Option) func(next http.Handler) http.Handler {
 	opt := newOptions(opts...)
 
@@ -22,19 +33,66 @@ func AccessToken(opts ...Option) func(next http.Handler) http.Handler {
 		}
 
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			token := r.Header.Get("x-access-token")
-			if token != "" {
-				u, err := tokenManager.DismantleToken(r.Context(), token)
+			t := r.Header.Get(token.TokenHeader)
+			if t != "" {
+				u, err := tokenManager.DismantleToken(r.Context(), t)
 				if err != nil {
-					opt.Logger.Error().Err(err).Msg("could not dismantle token")
-					w.WriteHeader(http.StatusInternalServerError)
+					opt.Logger.Debug().Err(err).Msg("could not dismantle token")
+					unauthorized(w, r, "")
+					return
+				}
+				// tokens stay valid until they expire, so accounts that were disabled or deleted are rejected here
+				if !accountActive(w, r, opt, u.GetId().GetOpaqueId(), "") {
 					return
 				}
-				// store user in context for request
-				r = r.WithContext(user.ContextSetUser(r.Context(), u))
+				r = r.WithContext(contextWithUser(r.Context(), u, t))
 			}
 
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// accountActive looks up the account of an authenticated token and renders an error if it does not exist or is
+// disabled. Rejected tokens are rendered with the given challenge. The check is skipped if no accounts service is
+// configured.
+func accountActive(w http.ResponseWriter, r *http.Request, opt Options, userid, challenge string) bool {
+	if opt.AccountsService == nil {
+		return true
+	}
+	a, err := opt.AccountsService.GetAccount(r.Context(), &accounts.GetAccountRequest{Id: userid})
+	switch {
+	case err != nil && merrors.FromError(err).Code == http.StatusNotFound:
+		opt.Logger.Debug().Str("userid", userid).Msg("account of token does not exist")
+		unauthorized(w, r, challenge)
+		return false
+	case err != nil:
+		opt.Logger.Error().Err(err).Str("userid", userid).Msg("could not get account of token")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get account"))
+		return false
+	case !a.AccountEnabled:
+		opt.Logger.Debug().Str("userid", userid).Msg("account of token is disabled")
+		unauthorized(w, r, challenge)
+		return false
+	}
+	return true
+}
+
+// unauthorized renders an ocs 997 error. A non empty challenge is sent in the WWW-Authenticate header to tell
+// the client how to authenticate.
+func unauthorized(w http.ResponseWriter, r *http.Request, challenge string) {
+	if challenge != "" {
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	render.Render(w, r, response.ErrRender(data.MetaUnauthorized.StatusCode, "Unauthorised"))
+}
+
+// contextWithUser stores the authenticated user and its access token in the context of the request
+func contextWithUser(ctx context.Context, u *userpb.User, t string) context.Context {
+	recordUser(ctx, u.GetId().GetOpaqueId())
+	// store user in context for request
+	ctx = user.ContextSetUser(ctx, u)
+	// store the token so calls to the reva gateway are made on behalf of the user
+	ctx = token.ContextSetToken(ctx, t)
+	return metadata.AppendToOutgoingContext(ctx, token.TokenHeader, t)
+}
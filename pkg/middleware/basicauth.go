@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/token/manager/jwt"
+	"github.com/cs3org/reva/pkg/user"
+	"github.com/go-chi/render"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+// basicChallenge asks clients for basic credentials
+const basicChallenge = `Basic realm="ocis", charset="UTF-8"`
+
+// ErrInvalidCredentials is returned by password verifiers if the username and password do not match an account
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// BasicAuth middleware authenticates requests with http basic credentials, unless an access token already did.
+// It sets the same user context as the AccessToken middleware and mints an access token for calls to reva.
+// It renders ocs errors, so it has to run after the version middleware.
+func BasicAuth(opts ...Option) func(next http.Handler) http.Handler {
+	opt := newOptions(opts...)
+
+	return func(next http.Handler) http.Handler {
+		tokenManager, err := jwt.New(map[string]interface{}{
+			"secret":  opt.TokenManagerConfig.JWTSecret,
+			"expires": int64(60),
+		})
+		if err != nil {
+			opt.Logger.Fatal().Err(err).Msgf("Could not initialize token-manager")
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := user.ContextGetUser(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			account, err := opt.VerifyPassword(r.Context(), username, password)
+			switch {
+			case err == ErrInvalidCredentials:
+				opt.Logger.Debug().Str("username", username).Msg("invalid basic auth credentials")
+				unauthorized(w, r, basicChallenge)
+				return
+			case err != nil:
+				opt.Logger.Error().Err(err).Str("username", username).Msg("could not verify basic auth credentials")
+				render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not verify credentials"))
+				return
+			case !account.AccountEnabled:
+				opt.Logger.Debug().Str("userid", account.Id).Msg("account of basic auth credentials is disabled")
+				unauthorized(w, r, basicChallenge)
+				return
+			}
+
+			u := accountUser(account)
+			t, err := tokenManager.MintToken(r.Context(), u)
+			if err != nil {
+				opt.Logger.Error().Err(err).Str("userid", account.Id).Msg("could not mint token")
+				render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not mint token"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(contextWithUser(r.Context(), u, t)))
+		})
+	}
+}
+
+// accountUser converts an account into the reva user stored in the request context
+func accountUser(a *accounts.Account) *userpb.User {
+	groups := make([]string, 0, len(a.MemberOf))
+	for _, g := range a.MemberOf {
+		groups = append(groups, g.OnPremisesSamAccountName)
+	}
+	return &userpb.User{
+		Id:          &userpb.UserId{OpaqueId: a.Id},
+		Username:    a.OnPremisesSamAccountName,
+		Mail:        a.Mail,
+		DisplayName: a.DisplayName,
+		Groups:      groups,
+	}
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/token/manager/jwt"
+	"github.com/cs3org/reva/pkg/user"
+	"github.com/go-chi/render"
+	merrors "github.com/micro/go-micro/v2/errors"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+// invalidTokenChallenge tells clients that the bearer token was rejected
+const invalidTokenChallenge = `Bearer realm="ocis", error="invalid_token"`
+
+// defaultKeyRefresh is used if no refresh interval of the signing keys is configured
+const defaultKeyRefresh = time.Hour
+
+const (
+	// defaultUserClaim is used if no claim identifying the account is configured
+	defaultUserClaim = "preferred_username"
+	// defaultAccountField is used if no account field the user claim is compared to is configured
+	defaultAccountField = "on_premises_sam_account_name"
+)
+
+// accountFields are the account fields the user claim of a bearer token can be compared to
+var accountFields = map[string]bool{
+	"id":                           true,
+	"on_premises_sam_account_name": true,
+	"preferred_name":               true,
+	"mail":                         true,
+}
+
+// supportedSigningAlgs are the algorithms bearer tokens may be signed with
+var supportedSigningAlgs = []string{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
+}
+
+// claims are the claims of a bearer token that are mapped to the reva user
+type claims struct {
+	Subject           string   `json:"sub"`
+	PreferredUsername string   `json:"preferred_username"`
+	Email             string   `json:"email"`
+	Name              string   `json:"name"`
+	Groups            []string `json:"groups"`
+}
+
+// BearerAuth middleware authenticates requests with openid connect bearer tokens, unless an access token already
+// did. Tokens must be issued by the configured issuer for the configured audience and must not be expired.
+// The account of a token is found by comparing the configured user claim with the configured account field, so
+// that tokens of issuers that do not know the account ids are accepted. Tokens of accounts that do not exist or
+// are disabled are rejected. It sets the same user context as the AccessToken middleware and mints an access
+// token for calls to reva. It renders ocs errors, so it has to run after the version middleware.
+func BearerAuth(opts ...Option) func(next http.Handler) http.Handler {
+	opt := newOptions(opts...)
+
+	return func(next http.Handler) http.Handler {
+		tokenManager, err := jwt.New(map[string]interface{}{
+			"secret":  opt.TokenManagerConfig.JWTSecret,
+			"expires": int64(60),
+		})
+		if err != nil {
+			opt.Logger.Fatal().Err(err).Msgf("Could not initialize token-manager")
+		}
+
+		var load keyLoader
+		switch {
+		case opt.OIDC.JWKSFile != "":
+			load = fileKeys(opt.OIDC.JWKSFile)
+		case opt.OIDC.JWKSURL != "":
+			load = remoteKeys(opt.OIDC.JWKSURL)
+		default:
+			opt.Logger.Fatal().Str("issuer", opt.OIDC.Issuer).Msg("No jwks url or file of the openid connect issuer configured")
+		}
+		if opt.OIDC.Audience == "" {
+			opt.Logger.Fatal().Str("issuer", opt.OIDC.Issuer).Msg("No audience of bearer tokens configured")
+		}
+		userClaim := opt.OIDC.UserClaim
+		if userClaim == "" {
+			userClaim = defaultUserClaim
+		}
+		accountField := opt.OIDC.AccountField
+		if accountField == "" {
+			accountField = defaultAccountField
+		}
+		if !accountFields[accountField] {
+			opt.Logger.Fatal().Str("field", accountField).Msg("Unknown account field for the user claim of bearer tokens")
+		}
+		if accountField != "id" && opt.AccountsService == nil {
+			opt.Logger.Fatal().Str("field", accountField).Msg("No accounts service to look up the user claim of bearer tokens")
+		}
+		refresh := opt.OIDC.JWKSRefresh
+		if refresh <= 0 {
+			refresh = defaultKeyRefresh
+		}
+
+		verifier := oidc.NewVerifier(opt.OIDC.Issuer, newKeySet(load, refresh), &oidc.Config{
+			ClientID:             opt.OIDC.Audience,
+			SupportedSigningAlgs: supportedSigningAlgs,
+		})
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := user.ContextGetUser(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			auth := r.Header.Get("Authorization")
+			if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			idToken, err := verifier.Verify(r.Context(), strings.TrimSpace(auth[7:]))
+			if err != nil {
+				opt.Logger.Debug().Err(err).Msg("invalid bearer token")
+				unauthorized(w, r, invalidTokenChallenge)
+				return
+			}
+			c, all := &claims{}, map[string]interface{}{}
+			err = idToken.Claims(c)
+			if err == nil {
+				err = idToken.Claims(&all)
+			}
+			if err != nil || c.Subject == "" {
+				opt.Logger.Debug().Err(err).Msg("invalid claims in bearer token")
+				unauthorized(w, r, invalidTokenChallenge)
+				return
+			}
+			value, _ := all[userClaim].(string)
+			if value == "" {
+				opt.Logger.Debug().Str("claim", userClaim).Msg("user claim missing in bearer token")
+				unauthorized(w, r, invalidTokenChallenge)
+				return
+			}
+
+			userid, err := accountID(r.Context(), opt, accountField, value)
+			switch {
+			case err != nil && merrors.FromError(err).Code == http.StatusNotFound:
+				opt.Logger.Debug().Str(userClaim, value).Msg("account of token does not exist")
+				unauthorized(w, r, invalidTokenChallenge)
+				return
+			case err != nil:
+				opt.Logger.Error().Err(err).Str(userClaim, value).Msg("could not look up account of token")
+				render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get account"))
+				return
+			}
+
+			// the issuer does not know about accounts disabled in ocis, so they are rejected like invalid tokens
+			if !accountActive(w, r, opt, userid, invalidTokenChallenge) {
+				return
+			}
+
+			u := claimsUser(idToken.Issuer, userid, c)
+			t, err := tokenManager.MintToken(r.Context(), u)
+			if err != nil {
+				opt.Logger.Error().Err(err).Str("userid", userid).Msg("could not mint token")
+				render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not mint token"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(contextWithUser(r.Context(), u, t)))
+		})
+	}
+}
+
+// accountID finds the id of the account whose field has the value of the user claim
+func accountID(ctx context.Context, opt Options, field, value string) (string, error) {
+	if field == "id" {
+		return value, nil
+	}
+	res, err := opt.AccountsService.ListAccounts(ctx, &accounts.ListAccountsRequest{
+		Query: fmt.Sprintf("%s eq '%s'", field, strings.ReplaceAll(value, "'", "''")),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(res.Accounts) != 1 {
+		return "", merrors.NotFound("com.owncloud.api.ocs", "no account with %s %s", field, value)
+	}
+	return res.Accounts[0].Id, nil
+}
+
+// claimsUser maps the claims of a bearer token to the reva user of the account stored in the request context
+func claimsUser(issuer, userid string, c *claims) *userpb.User {
+	username := c.PreferredUsername
+	if username == "" {
+		username = c.Subject
+	}
+	return &userpb.User{
+		Id:          &userpb.UserId{Idp: issuer, OpaqueId: userid},
+		Username:    username,
+		Mail:        c.Email,
+		DisplayName: c.Name,
+		Groups:      c.Groups,
+	}
+}
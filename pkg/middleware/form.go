@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/render"
+
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+const (
+	// maxBodySize limits the size of request bodies like the form parsing of net/http does
+	maxBodySize = 10 << 20
+	// maxFormMemory is the number of bytes of a multipart body kept in memory
+	maxFormMemory = 32 << 20
+)
+
+// ParseForm middleware decodes form-urlencoded, multipart and JSON request bodies into the request form,
+// so that handlers can use PostFormValue regardless of the encoding. Unlike the standard library it also
+// reads the body of DELETE requests, oc10 clients send parameters like the groupid there.
+// Bodies larger than 10 MB are rejected. It has to run after the version middleware to render ocs errors
+// and after the authentication middlewares, so that requests with invalid credentials are rejected before
+// the body is read.
+func ParseForm(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		}
+		if err := parseForm(r); err != nil {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "invalid request body: "+err.Error()))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func parseForm(r *http.Request) error {
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		// requests without a body usually have no content type
+		ct = ""
+	}
+
+	var body url.Values
+	switch ct {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(maxFormMemory); err != nil {
+			return err
+		}
+		return nil
+	case "application/json":
+		if body, err = decodeJSON(r); err != nil {
+			return err
+		}
+	case "application/x-www-form-urlencoded", "":
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		if body, err = url.ParseQuery(string(b)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported content type %s", ct)
+	}
+
+	// the query parameters are part of the form, values from the body take precedence
+	query := r.URL.Query()
+	r.PostForm = body
+	r.Form = make(url.Values, len(body)+len(query))
+	for k, v := range body {
+		r.Form[k] = append(r.Form[k], v...)
+	}
+	for k, v := range query {
+		r.Form[k] = append(r.Form[k], v...)
+	}
+	return nil
+}
+
+// decodeJSON converts the top level values of a JSON object into form values. Arrays become multiple values,
+// which are also available with the [] suffix used by form encoded requests, e.g. groups[]. Nested objects
+// cannot be expressed as form values, so the body is kept for handlers decoding structured requests.
+func decodeJSON(r *http.Request) (url.Values, error) {
+	values := url.Values{}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if len(bytes.TrimSpace(b)) == 0 {
+		return values, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var body map[string]interface{}
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+
+	for k, v := range body {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, e := range v {
+				s, ok := jsonValue(e)
+				if !ok {
+					continue
+				}
+				values.Add(k, s)
+				if !strings.HasSuffix(k, "[]") {
+					values.Add(k+"[]", s)
+				}
+			}
+		default:
+			if s, ok := jsonValue(v); ok {
+				values.Set(k, s)
+			}
+		}
+	}
+	return values, nil
+}
+
+// jsonValue returns the form value of a JSON scalar, false for objects and arrays
+func jsonValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	case nil:
+		return "", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// minKeyRefresh limits how often the keys are reloaded when tokens are signed with an unknown key
+	minKeyRefresh = 10 * time.Second
+	// keyFetchTimeout limits how long loading the keys from the issuer may take
+	keyFetchTimeout = 10 * time.Second
+)
+
+// keyLoader loads the signing keys of an issuer
+type keyLoader func(ctx context.Context) (*jose.JSONWebKeySet, error)
+
+// keySet caches the signing keys of an openid connect issuer. The keys are reloaded after the refresh interval and,
+// to pick up rotated keys early, when a token is signed with an unknown key. If reloading fails the cached keys
+// are used until the next attempt. Keys are reloaded in the background, only requests that need a key that is not
+// cached wait for the reload.
+type keySet struct {
+	load    keyLoader
+	refresh time.Duration
+
+	mu        sync.Mutex
+	keys      *jose.JSONWebKeySet
+	loaded    time.Time
+	attempted time.Time
+	// loading is closed when the running reload finished, it is nil if no reload is running
+	loading chan struct{}
+	// err is the error of the last reload
+	err error
+}
+
+func newKeySet(load keyLoader, refresh time.Duration) *keySet {
+	return &keySet{
+		load:    load,
+		refresh: refresh,
+	}
+}
+
+// VerifySignature implements the oidc.KeySet interface.
+func (k *keySet) VerifySignature(ctx context.Context, token string) ([]byte, error) {
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("token must have exactly one signature")
+	}
+
+	keys, err := k.keysFor(ctx, jws.Signatures[0].Header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if payload, err := jws.Verify(&keys[i]); err == nil {
+			return payload, nil
+		}
+	}
+	return nil, errors.New("invalid token signature")
+}
+
+// keysFor returns the keys with the key id, reloading the keys if they expired or the key id is unknown
+func (k *keySet) keysFor(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	k.mu.Lock()
+	keys := k.find(kid)
+	now := time.Now()
+	expired := now.Sub(k.loaded) > k.refresh
+	if (len(keys) == 0 || expired) && k.loading == nil && now.Sub(k.attempted) >= minKeyRefresh {
+		k.attempted = now
+		k.loading = make(chan struct{})
+		go k.reload(k.loading)
+	}
+	loading := k.loading
+	err := k.err
+	k.mu.Unlock()
+
+	// expired keys are used while they are reloaded
+	if len(keys) == 0 && loading != nil {
+		select {
+		case <-loading:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		k.mu.Lock()
+		keys = k.find(kid)
+		err = k.err
+		k.mu.Unlock()
+	}
+
+	if len(keys) == 0 {
+		if err != nil {
+			return nil, fmt.Errorf("could not load signing keys: %w", err)
+		}
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return keys, nil
+}
+
+// reload loads the keys and closes done afterwards. It does not use the context of the request that triggered it,
+// because other requests wait for the keys as well.
+func (k *keySet) reload(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyFetchTimeout)
+	defer cancel()
+	set, err := k.load(ctx)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err == nil {
+		k.keys = set
+		k.loaded = time.Now()
+	}
+	k.err = err
+	k.loading = nil
+	close(done)
+}
+
+// find returns the cached keys with the key id, tokens without key id are checked against all keys
+func (k *keySet) find(kid string) []jose.JSONWebKey {
+	if k.keys == nil {
+		return nil
+	}
+	if kid == "" {
+		return k.keys.Keys
+	}
+	return k.keys.Key(kid)
+}
+
+// remoteKeys loads the keys from the jwks endpoint of the issuer
+func remoteKeys(url string) keyLoader {
+	client := &http.Client{Timeout: keyFetchTimeout}
+	return func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d from %s", res.StatusCode, url)
+		}
+		set := &jose.JSONWebKeySet{}
+		if err := json.NewDecoder(res.Body).Decode(set); err != nil {
+			return nil, fmt.Errorf("invalid keys from %s: %w", url, err)
+		}
+		return set, nil
+	}
+}
+
+// fileKeys loads the keys from a local jwks file, replacing the file rotates the keys
+func fileKeys(path string) keyLoader {
+	return func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		set := &jose.JSONWebKeySet{}
+		if err := json.Unmarshal(b, set); err != nil {
+			return nil, fmt.Errorf("invalid keys in %s: %w", path, err)
+		}
+		return set, nil
+	}
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// testIssuer signs tokens and serves its keys to a key set, counting the loads
+type testIssuer struct {
+	t       *testing.T
+	signers map[string]*rsa.PrivateKey
+
+	mu    sync.Mutex
+	kids  []string
+	err   error
+	loads int
+}
+
+func newTestIssuer(t *testing.T, kids ...string) *testIssuer {
+	i := &testIssuer{t: t, signers: map[string]*rsa.PrivateKey{}}
+	i.publish(kids...)
+	return i
+}
+
+// publish replaces the published keys, creating the keys that do not exist yet
+func (i *testIssuer) publish(kids ...string) {
+	for _, kid := range kids {
+		if _, ok := i.signers[kid]; ok {
+			continue
+		}
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			i.t.Fatal(err)
+		}
+		i.signers[kid] = key
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.kids = kids
+	i.err = nil
+}
+
+// fail makes the following loads fail
+func (i *testIssuer) fail(err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.err = err
+}
+
+func (i *testIssuer) load(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.loads++
+	if i.err != nil {
+		return nil, i.err
+	}
+	set := &jose.JSONWebKeySet{}
+	for _, kid := range i.kids {
+		set.Keys = append(set.Keys, jose.JSONWebKey{Key: &i.signers[kid].PublicKey, KeyID: kid, Algorithm: "RS256", Use: "sig"})
+	}
+	return set, nil
+}
+
+func (i *testIssuer) loadCount() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.loads
+}
+
+func (i *testIssuer) sign(kid string) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: i.signers[kid], KeyID: kid}},
+		nil,
+	)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	jws, err := signer.Sign([]byte(`{"sub":"einstein"}`))
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	raw, err := jws.CompactSerialize()
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return raw
+}
+
+// allowReload lets the next token with an unknown key reload the keys, as if minKeyRefresh passed
+func allowReload(k *keySet) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.attempted = time.Now().Add(-minKeyRefresh)
+}
+
+// waitForReload waits until a reload running in the background finished
+func waitForReload(k *keySet) {
+	k.mu.Lock()
+	loading := k.loading
+	k.mu.Unlock()
+	if loading != nil {
+		<-loading
+	}
+}
+
+func TestKeySetReloadsUnknownKeys(t *testing.T) {
+	issuer := newTestIssuer(t, "first")
+	k := newKeySet(issuer.load, time.Hour)
+	ctx := context.Background()
+
+	_, err := k.VerifySignature(ctx, issuer.sign("first"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, issuer.loadCount(), "the keys are loaded by the first token")
+
+	_, err = k.VerifySignature(ctx, issuer.sign("first"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, issuer.loadCount(), "cached keys are not reloaded")
+
+	issuer.publish("first", "second")
+	allowReload(k)
+	_, err = k.VerifySignature(ctx, issuer.sign("second"))
+	assert.NoError(t, err, "a token signed with a rotated key is accepted")
+	assert.Equal(t, 2, issuer.loadCount(), "an unknown key id reloads the keys")
+}
+
+func TestKeySetLimitsReloads(t *testing.T) {
+	issuer := newTestIssuer(t, "first")
+	k := newKeySet(issuer.load, time.Hour)
+	ctx := context.Background()
+
+	_, err := k.VerifySignature(ctx, issuer.sign("first"))
+	assert.NoError(t, err)
+
+	issuer.publish("first", "second")
+	for i := 0; i < 5; i++ {
+		_, err = k.VerifySignature(ctx, issuer.sign("second"))
+		assert.EqualError(t, err, `unknown signing key "second"`)
+	}
+	assert.Equal(t, 1, issuer.loadCount(), "the keys are not reloaded more often than minKeyRefresh")
+
+	allowReload(k)
+	_, err = k.VerifySignature(ctx, issuer.sign("second"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, issuer.loadCount(), "the keys are reloaded once minKeyRefresh passed")
+}
+
+func TestKeySetKeepsKeysOnFailedReload(t *testing.T) {
+	issuer := newTestIssuer(t, "first")
+	k := newKeySet(issuer.load, time.Hour)
+	ctx := context.Background()
+
+	_, err := k.VerifySignature(ctx, issuer.sign("first"))
+	assert.NoError(t, err)
+
+	issuer.publish("first", "second")
+	issuer.fail(errors.New("issuer unavailable"))
+	allowReload(k)
+	_, err = k.VerifySignature(ctx, issuer.sign("second"))
+	assert.EqualError(t, err, "could not load signing keys: issuer unavailable")
+	assert.Equal(t, 2, issuer.loadCount())
+
+	_, err = k.VerifySignature(ctx, issuer.sign("first"))
+	assert.NoError(t, err, "the cached keys are used after a failed reload")
+
+	// expired keys are used while they are reloaded in the background
+	k.mu.Lock()
+	k.loaded = time.Now().Add(-2 * time.Hour)
+	k.mu.Unlock()
+	allowReload(k)
+	_, err = k.VerifySignature(ctx, issuer.sign("first"))
+	assert.NoError(t, err, "expired keys are used while they are reloaded")
+	waitForReload(k)
+	assert.Equal(t, 3, issuer.loadCount(), "expired keys are reloaded")
+
+	_, err = k.VerifySignature(ctx, issuer.sign("first"))
+	assert.NoError(t, err, "the expired keys are kept after a failed reload")
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"context"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
 	"github.com/owncloud/ocis-ocs/pkg/config"
 	"github.com/owncloud/ocis-pkg/v2/log"
 )
@@ -14,8 +17,19 @@ type Options struct {
 	Logger log.Logger
 	// TokenManagerConfig for communicating with the reva token manager
 	TokenManagerConfig config.TokenManager
+	// AccountsService is used to find the accounts of bearer tokens and to reject tokens of disabled accounts, the
+	// check is skipped if it is not set
+	AccountsService accounts.AccountsService
+	// VerifyPassword checks basic auth credentials, it must be set for the basic auth middleware
+	VerifyPassword PasswordVerifier
+	// OIDC configures the issuer of bearer tokens, it must be set for the bearer auth middleware
+	OIDC config.OIDC
 }
 
+// PasswordVerifier returns the account with the username if the password is correct.
+// It returns ErrInvalidCredentials if the username and password do not match an account.
+type PasswordVerifier func(ctx context.Context, username, password string) (*accounts.Account, error)
+
 // newOptions initializes the available default options.
 func newOptions(opts ...Option) Options {
 	opt := Options{}
@@ -40,3 +54,24 @@ func TokenManagerConfig(cfg config.TokenManager) Option {
 		o.TokenManagerConfig = cfg
 	}
 }
+
+// AccountsService provides a function to set the accounts service option.
+func AccountsService(as accounts.AccountsService) Option {
+	return func(o *Options) {
+		o.AccountsService = as
+	}
+}
+
+// VerifyPassword provides a function to set the password verifier option.
+func VerifyPassword(v PasswordVerifier) Option {
+	return func(o *Options) {
+		o.VerifyPassword = v
+	}
+}
+
+// OIDC provides a function to set the openid connect config option.
+func OIDC(cfg config.OIDC) Option {
+	return func(o *Options) {
+		o.OIDC = cfg
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import "context"
+
+type contextKey int
+
+const (
+	// userRecorderKey holds the pointer the id of the authenticated user is stored in
+	userRecorderKey contextKey = iota
+)
+
+// RecordUser returns a context in which the authentication middleware stores the id of the authenticated user
+// in userid, so that services wrapping the handlers can log it
+func RecordUser(ctx context.Context, userid *string) context.Context {
+	return context.WithValue(ctx, userRecorderKey, userid)
+}
+
+// recordUser stores the id of the authenticated user if the request is recorded
+func recordUser(ctx context.Context, userid string) {
+	if p, ok := ctx.Value(userRecorderKey).(*string); ok {
+		*p = userid
+	}
+}
@@ -0,0 +1,216 @@
+// Package notifications persists the notifications shown by the ocs notifications app in the ocis-store.
+//
+// Other extensions create notifications for a user in one of three ways:
+//
+// Over http, admins and service accounts with the admin permission call the ocs endpoint
+// POST /ocs/v{1,2}.php/apps/notifications/api/v1/admin_notifications/{userid} with the form parameters app,
+// subject, message, link, object_type and object_id. Only the subject is required.
+//
+// In go, extensions that import this package use a Publisher, e.g. the Manager returned by NewManager.
+//
+// Extensions that talk to the ocis-store directly write a record to the table Table of the database Database.
+// The key of the record is "<user id>/<notification id>", the notification id must be unique for the user, e.g. a
+// uuid. The value is the json encoding of a Notification, with the datetime in RFC 3339 format. The user field has
+// to match the user id of the key.
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	merrors "github.com/micro/go-micro/v2/errors"
+	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
+)
+
+const (
+	// Database is the ocis-store database holding the notifications
+	Database = "ocs"
+	// Table is the ocis-store table holding the notifications
+	Table = "notifications"
+)
+
+// ErrNotFound is returned when a notification does not exist
+var ErrNotFound = errors.New("notification not found")
+
+// Notification is a message for a single user
+type Notification struct {
+	ID         string    `json:"id"`
+	App        string    `json:"app"`
+	User       string    `json:"user"`
+	Datetime   time.Time `json:"datetime"`
+	ObjectType string    `json:"object_type"`
+	ObjectID   string    `json:"object_id"`
+	Subject    string    `json:"subject"`
+	Message    string    `json:"message"`
+	Link       string    `json:"link"`
+}
+
+// Publisher creates notifications
+type Publisher interface {
+	Publish(ctx context.Context, n *Notification) (*Notification, error)
+}
+
+// Manager stores and retrieves the notifications of users
+type Manager struct {
+	store storepb.StoreService
+}
+
+// NewManager returns a manager using the given store service
+func NewManager(store storepb.StoreService) *Manager {
+	return &Manager{store: store}
+}
+
+// Publish stores a new notification for the user set in the notification.
+// The id and, if missing, the datetime are filled in.
+func (m *Manager) Publish(ctx context.Context, n *Notification) (*Notification, error) {
+	if n.User == "" {
+		return nil, errors.New("notification has no user")
+	}
+	if n.App == "" {
+		return nil, errors.New("notification has no app")
+	}
+	if n.Subject == "" {
+		return nil, errors.New("notification has no subject")
+	}
+
+	stored := *n
+	stored.ID = uuid.New().String()
+	if stored.Datetime.IsZero() {
+		stored.Datetime = time.Now()
+	}
+	stored.Datetime = stored.Datetime.UTC()
+
+	value, err := json.Marshal(&stored)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.store.Write(ctx, &storepb.WriteRequest{
+		Options: &storepb.WriteOptions{
+			Database: Database,
+			Table:    Table,
+		},
+		Record: &storepb.Record{
+			Key:   key(stored.User, stored.ID),
+			Value: value,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// List returns all notifications of the user, newest first
+func (m *Manager) List(ctx context.Context, userID string) ([]*Notification, error) {
+	res, err := m.store.Read(ctx, &storepb.ReadRequest{
+		Options: &storepb.ReadOptions{
+			Database: Database,
+			Table:    Table,
+			Prefix:   true,
+		},
+		Key: key(userID, ""),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return []*Notification{}, nil
+		}
+		return nil, err
+	}
+
+	list := make([]*Notification, 0, len(res.Records))
+	for _, r := range res.Records {
+		n := &Notification{}
+		if err := json.Unmarshal(r.Value, n); err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Datetime.After(list[j].Datetime)
+	})
+	return list, nil
+}
+
+// Get returns a single notification of the user
+func (m *Manager) Get(ctx context.Context, userID, id string) (*Notification, error) {
+	if id == "" {
+		return nil, ErrNotFound
+	}
+
+	res, err := m.store.Read(ctx, &storepb.ReadRequest{
+		Options: &storepb.ReadOptions{
+			Database: Database,
+			Table:    Table,
+		},
+		Key: key(userID, id),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if len(res.Records) == 0 {
+		return nil, ErrNotFound
+	}
+
+	n := &Notification{}
+	if err := json.Unmarshal(res.Records[0].Value, n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Delete removes a single notification of the user
+func (m *Manager) Delete(ctx context.Context, userID, id string) error {
+	if _, err := m.Get(ctx, userID, id); err != nil {
+		return err
+	}
+
+	_, err := m.store.Delete(ctx, &storepb.DeleteRequest{
+		Options: &storepb.DeleteOptions{
+			Database: Database,
+			Table:    Table,
+		},
+		Key: key(userID, id),
+	})
+	return err
+}
+
+// DeleteAll removes all notifications of the user
+func (m *Manager) DeleteAll(ctx context.Context, userID string) error {
+	list, err := m.List(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, n := range list {
+		_, err := m.store.Delete(ctx, &storepb.DeleteRequest{
+			Options: &storepb.DeleteOptions{
+				Database: Database,
+				Table:    Table,
+			},
+			Key: key(userID, n.ID),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key builds the store key of a notification. Keys are prefixed with the user id so that the notifications
+// of a user can be read with a single prefix query.
+func key(userID, id string) string {
+	return userID + "/" + id
+}
+
+func isNotFound(err error) bool {
+	return merrors.Parse(err.Error()).Code == http.StatusNotFound
+}
@@ -0,0 +1,157 @@
+package debug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/client"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/registry"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/config"
+	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
+)
+
+const (
+	accountsService = "com.owncloud.api.accounts"
+	storeService    = "com.owncloud.api.store"
+
+	// checkTimeout limits how long a dependency may take to answer the readiness check
+	checkTimeout = 5 * time.Second
+	// probeID is looked up in the backends, it is not expected to exist
+	probeID = "ocs-readiness-probe"
+
+	statusOK     = "ok"
+	statusFailed = "failed"
+)
+
+// Report is the result of the readiness check
+type Report struct {
+	Status string   `json:"status"`
+	Checks []*Check `json:"checks"`
+}
+
+// Check is the result of checking a single dependency
+type Check struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// checkFunc returns an error if the dependency is not usable
+type checkFunc func(ctx context.Context) error
+
+// checkDependencies runs all checks concurrently, the report is only ok if all checks passed.
+// The services are looked up in the registry and called with the client.
+func checkDependencies(ctx context.Context, cfg *config.Config, c client.Client, reg registry.Registry) *Report {
+	checks := []struct {
+		name  string
+		check checkFunc
+	}{
+		{"jwt-secret", checkJWTSecret(cfg)},
+		{accountsService, checkAccounts(c, reg)},
+		{storeService, checkStore(c, reg)},
+	}
+
+	report := &Report{
+		Status: statusOK,
+		Checks: make([]*Check, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			result := &Check{
+				Name:   checks[i].name,
+				Status: statusOK,
+			}
+			if err := checks[i].check(ctx); err != nil {
+				result.Status = statusFailed
+				result.Message = err.Error()
+			}
+			result.Duration = time.Since(start).String()
+			report.Checks[i] = result
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != statusOK {
+			report.Status = statusFailed
+		}
+	}
+	return report
+}
+
+// checkJWTSecret fails if no secret to validate access tokens is configured
+func checkJWTSecret(cfg *config.Config) checkFunc {
+	return func(ctx context.Context) error {
+		if cfg.TokenManager.JWTSecret == "" {
+			return errors.New("no jwt secret configured")
+		}
+		return nil
+	}
+}
+
+// checkAccounts looks up an account that does not exist, not found is a valid answer
+func checkAccounts(c client.Client, reg registry.Registry) checkFunc {
+	return func(ctx context.Context) error {
+		if err := resolve(reg, accountsService); err != nil {
+			return err
+		}
+		_, err := accounts.NewAccountsService(accountsService, c).GetAccount(ctx, &accounts.GetAccountRequest{Id: probeID})
+		return answered(err)
+	}
+}
+
+// checkStore reads a record that does not exist, not found is a valid answer
+func checkStore(c client.Client, reg registry.Registry) checkFunc {
+	return func(ctx context.Context) error {
+		if err := resolve(reg, storeService); err != nil {
+			return err
+		}
+		_, err := storepb.NewStoreService(storeService, c).Read(ctx, &storepb.ReadRequest{
+			Options: &storepb.ReadOptions{
+				Database: "ocs",
+				Table:    "readiness",
+			},
+			Key: probeID,
+		})
+		return answered(err)
+	}
+}
+
+// resolve fails if the service has no nodes in the micro registry
+func resolve(reg registry.Registry, name string) error {
+	services, err := reg.GetService(name)
+	if err != nil {
+		return fmt.Errorf("could not resolve %s: %w", name, err)
+	}
+	for _, s := range services {
+		if len(s.Nodes) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("no nodes of %s registered", name)
+}
+
+// answered ignores not found errors, they show that the service is able to handle requests
+func answered(err error) error {
+	if err == nil || merrors.FromError(err).Code == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/grpc"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-micro/v2/registry/memory"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owncloud/ocis-ocs/pkg/config"
+)
+
+// fakeClient answers calls with the error configured for the called service
+type fakeClient struct {
+	client.Client
+	errs map[string]error
+}
+
+func (c fakeClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return c.errs[req.Service()]
+}
+
+func TestReady(t *testing.T) {
+	testData := []struct {
+		secret      string
+		registered  []string
+		errs        map[string]error
+		status      int
+		failed      []string
+		description string
+	}{
+		{
+			secret:      "secret",
+			registered:  []string{accountsService, storeService},
+			errs:        map[string]error{accountsService: merrors.NotFound(accountsService, "account not found")},
+			status:      http.StatusOK,
+			description: "all dependencies are up",
+		},
+		{
+			secret:      "",
+			registered:  []string{accountsService, storeService},
+			status:      http.StatusServiceUnavailable,
+			failed:      []string{"jwt-secret"},
+			description: "no jwt secret",
+		},
+		{
+			secret:      "secret",
+			registered:  []string{accountsService, storeService},
+			errs:        map[string]error{accountsService: errors.New("connection refused")},
+			status:      http.StatusServiceUnavailable,
+			failed:      []string{accountsService},
+			description: "accounts service does not answer",
+		},
+		{
+			secret:      "secret",
+			registered:  []string{accountsService},
+			status:      http.StatusServiceUnavailable,
+			failed:      []string{storeService},
+			description: "store service is not registered",
+		},
+	}
+
+	for _, data := range testData {
+		reg := memory.NewRegistry()
+		for _, name := range data.registered {
+			err := reg.Register(&registry.Service{
+				Name:  name,
+				Nodes: []*registry.Node{{Id: name + "-1", Address: "localhost:9999"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		cfg := config.New()
+		cfg.TokenManager.JWTSecret = data.secret
+		c := fakeClient{Client: grpc.NewClient(), errs: data.errs}
+
+		res := httptest.NewRecorder()
+		ready(cfg, c, reg)(res, httptest.NewRequest("GET", "/readyz", nil))
+
+		assert.Equal(t, data.status, res.Code, data.description)
+		assert.Equal(t, "application/json", res.Header().Get("Content-Type"), data.description)
+
+		var report Report
+		if err := json.Unmarshal(res.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		failed := []string{}
+		for _, check := range report.Checks {
+			if check.Status != statusOK {
+				failed = append(failed, check.Name)
+				assert.NotEmpty(t, check.Message, "%v: failed checks are expected to have a message", data.description)
+			}
+		}
+		if data.failed == nil {
+			assert.Equal(t, statusOK, report.Status, data.description)
+			assert.Empty(t, failed, data.description)
+		} else {
+			assert.Equal(t, statusFailed, report.Status, data.description)
+			assert.Equal(t, data.failed, failed, data.description)
+		}
+		assert.Len(t, report.Checks, 3, data.description)
+	}
+}
@@ -1,9 +1,14 @@
 package debug
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/grpc"
+	"github.com/micro/go-micro/v2/registry"
+
 	"github.com/owncloud/ocis-ocs/pkg/config"
 	"github.com/owncloud/ocis-ocs/pkg/version"
 	"github.com/owncloud/ocis-pkg/v2/service/debug"
@@ -22,30 +27,34 @@ func Server(opts ...Option) (*http.Server, error) {
 		debug.Pprof(options.Config.Debug.Pprof),
 		debug.Zpages(options.Config.Debug.Zpages),
 		debug.Health(health(options.Config)),
-		debug.Ready(ready(options.Config)),
+		debug.Ready(ready(options.Config, grpc.NewClient(), registry.DefaultRegistry)),
 	), nil
 }
 
-// health implements the health check.
+// health implements the health check. The service is healthy as long as it answers, the dependencies are
+// checked by the ready check.
 func health(cfg *config.Config) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusOK)
 
-		// TODO(tboerger): check if services are up and running
-
 		io.WriteString(w, http.StatusText(http.StatusOK))
 	}
 }
 
-// ready implements the ready check.
-func ready(cfg *config.Config) func(http.ResponseWriter, *http.Request) {
+// ready implements the ready check. It reports the state of every dependency as json and responds with
+// 503 if one of them is not usable.
+func ready(cfg *config.Config, c client.Client, reg registry.Registry) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusOK)
+		report := checkDependencies(r.Context(), cfg, c, reg)
 
-		// TODO(tboerger): check if services are up and running
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status == statusOK {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
 
-		io.WriteString(w, http.StatusText(http.StatusOK))
+		json.NewEncoder(w).Encode(report)
 	}
 }
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owncloud/ocis-ocs/pkg/config"
+	svc "github.com/owncloud/ocis-ocs/pkg/service/v0"
+	ocisLog "github.com/owncloud/ocis-pkg/v2/log"
+)
+
+func TestAccessLogRedaction(t *testing.T) {
+	const secret = "Sup3r-s3cret"
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: adminID}, Username: "moss"}
+
+	testData := []struct {
+		method      string
+		endpoint    string
+		body        url.Values
+		user        *userpb.User
+		redacted    []string
+		description string
+	}{
+		{
+			method:      "PUT",
+			endpoint:    "cloud/users/not-a-user",
+			body:        url.Values{"key": {"password"}, "value": {secret}},
+			user:        admin,
+			redacted:    []string{"value"},
+			description: "new password of the edit user endpoint",
+		},
+		{
+			method:      "PUT",
+			endpoint:    "cloud/user",
+			body:        url.Values{"key": {"password"}, "value": {secret}, "currentpassword": {secret + "-current"}},
+			user:        einstein,
+			redacted:    []string{"value", "currentpassword"},
+			description: "new and current password of the edit current user endpoint",
+		},
+		{
+			method:      "GET",
+			endpoint:    "cloud/capabilities?access_token=" + secret + "&client_secret=" + secret,
+			user:        einstein,
+			redacted:    []string{"access_token", "client_secret"},
+			description: "tokens and secrets in the query",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, data := range testData {
+			var buf bytes.Buffer
+			logger := ocisLog.Logger{Logger: zerolog.New(&buf)}
+			service := svc.NewLogging(getService(), logger, config.Log{})
+
+			_, err := sendRequestTo(service, data.method, fmt.Sprintf("/%v/%v", ocsVersion, data.endpoint), data.body.Encode(), data.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.NotContains(t, buf.String(), secret, "%v: secrets must not be logged", data.description)
+
+			var entry struct {
+				UserID string `json:"userid"`
+				Params string `json:"params"`
+			}
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				if strings.Contains(line, `"message":"access"`) {
+					if err := json.Unmarshal([]byte(line), &entry); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			assert.Equal(t, data.user.Id.OpaqueId, entry.UserID, data.description)
+
+			params, err := url.ParseQuery(entry.Params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range data.redacted {
+				assert.Equal(t, "***redacted***", params.Get(name), "%v: %v is expected to be redacted", data.description, name)
+			}
+			if data.body != nil {
+				assert.Equal(t, "password", params.Get("key"), "%v: other parameters are logged", data.description)
+			}
+		}
+	}
+}
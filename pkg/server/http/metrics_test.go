@@ -0,0 +1,71 @@
+package http
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/owncloud/ocis-ocs/pkg/metrics"
+	svc "github.com/owncloud/ocis-ocs/pkg/service/v0"
+)
+
+// gatherLabels returns the label sets of the series of every metric family in the registry by metric name
+func gatherLabels(t *testing.T, registry *prometheus.Registry) map[string][]map[string]string {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := map[string][]map[string]string{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			series[family.GetName()] = append(series[family.GetName()], labels)
+		}
+	}
+	return series
+}
+
+func labelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRequestMetrics(t *testing.T) {
+	m := metrics.New()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(m.Requests, m.Duration)
+	service := svc.NewInstrument(getService(), m)
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: adminID}, Username: "moss"}
+
+	for _, ocsVersion := range ocsVersions {
+		res, err := sendRequestTo(service, "GET", fmt.Sprintf("/%v/cloud/users/%v?format=json", ocsVersion, einstein.Id.OpaqueId), "", admin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertStatusCode(t, 200, res, ocsVersion)
+	}
+
+	series := gatherLabels(t, registry)
+	for _, name := range []string{"ocis_ocs_requests_total", "ocis_ocs_request_duration_seconds"} {
+		assert.Len(t, series[name], 2, "%v: one series per api version is expected", name)
+		for _, labels := range series[name] {
+			assert.Equal(t, []string{"format", "ocs_status", "route", "status", "version"}, labelNames(labels), name)
+			assert.Contains(t, labels["route"], "/cloud/users/{userid}", "%v: the route pattern is expected instead of the path", name)
+			assert.NotContains(t, labels["route"], einstein.Id.OpaqueId, "%v: user ids must not be part of the labels", name)
+			assert.Equal(t, "json", labels["format"], name)
+			assert.Equal(t, "200", labels["status"], name)
+			assert.Equal(t, "100", labels["ocs_status"], name)
+		}
+	}
+}
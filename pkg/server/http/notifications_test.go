@@ -0,0 +1,341 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/micro/go-micro/v2/client"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"github.com/owncloud/ocis-ocs/pkg/notifications"
+	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
+	"github.com/stretchr/testify/assert"
+)
+
+// storeService is used by the service under test instead of a real ocis-store
+var storeService = newFakeStore()
+
+// fakeStore is an in-memory fake of the ocis-store. Records are kept per database and table.
+type fakeStore struct {
+	storepb.StoreService
+
+	mu      sync.Mutex
+	records map[string]*storepb.Record
+	// writesUnavailable makes Write and Delete fail like an unreachable store
+	writesUnavailable bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{records: map[string]*storepb.Record{}}
+}
+
+func storeKey(database, table, key string) string {
+	return database + "|" + table + "|" + key
+}
+
+func (s *fakeStore) Read(ctx context.Context, in *storepb.ReadRequest, opts ...client.CallOption) (*storepb.ReadResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := storeKey(in.Options.Database, in.Options.Table, in.Key)
+	records := []*storepb.Record{}
+	for key, r := range s.records {
+		if key == k || (in.Options.Prefix && strings.HasPrefix(key, k)) {
+			records = append(records, r)
+		}
+	}
+	if len(records) == 0 {
+		return nil, merrors.NotFound("com.owncloud.api.store", "%s not found", in.Key)
+	}
+	return &storepb.ReadResponse{Records: records}, nil
+}
+
+func (s *fakeStore) Write(ctx context.Context, in *storepb.WriteRequest, opts ...client.CallOption) (*storepb.WriteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writesUnavailable {
+		return nil, merrors.InternalServerError("com.owncloud.api.store", "store unreachable")
+	}
+
+	s.records[storeKey(in.Options.Database, in.Options.Table, in.Record.Key)] = in.Record
+	return &storepb.WriteResponse{}, nil
+}
+
+func (s *fakeStore) Delete(ctx context.Context, in *storepb.DeleteRequest, opts ...client.CallOption) (*storepb.DeleteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writesUnavailable {
+		return nil, merrors.InternalServerError("com.owncloud.api.store", "store unreachable")
+	}
+
+	delete(s.records, storeKey(in.Options.Database, in.Options.Table, in.Key))
+	return &storepb.DeleteResponse{}, nil
+}
+
+type Notification struct {
+	NotificationID string `json:"notification_id" xml:"notification_id"`
+	App            string `json:"app" xml:"app"`
+	User           string `json:"user" xml:"user"`
+	Subject        string `json:"subject" xml:"subject"`
+	Message        string `json:"message" xml:"message"`
+	Link           string `json:"link" xml:"link"`
+}
+
+type ListNotificationsResponse struct {
+	Ocs struct {
+		Meta Meta           `json:"meta" xml:"meta"`
+		Data []Notification `json:"data" xml:"data>element"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+type SingleNotificationResponse struct {
+	Ocs struct {
+		Meta Meta         `json:"meta" xml:"meta"`
+		Data Notification `json:"data" xml:"data"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+func publishNotifications(t *testing.T, subjects ...string) []*notifications.Notification {
+	published := []*notifications.Notification{}
+	for i, subject := range subjects {
+		n, err := notifications.NewManager(storeService).Publish(context.Background(), &notifications.Notification{
+			App:      "files_sharing",
+			User:     einstein.Id.OpaqueId,
+			Datetime: time.Date(2020, 9, 1, 12, i, 0, 0, time.UTC),
+			Subject:  subject,
+			Link:     "https://localhost:9200/#/files/list/shared-with-me",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		published = append(published, n)
+	}
+	return published
+}
+
+func TestNotifications(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			storeService = newFakeStore()
+			formatpart := getFormatString(format)
+			published := publishNotifications(t, "Marie shared Photos with you", "Richard shared notes.txt with you")
+
+			// notifications of other users are not listed
+			_, err := notifications.NewManager(storeService).Publish(context.Background(), &notifications.Notification{
+				App:     "files_sharing",
+				User:    richardID,
+				Subject: "Albert shared Photos with you",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := sendRequestAs("GET", fmt.Sprintf("/%v/apps/notifications/api/v1/notifications%v", ocsVersion, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var list ListNotificationsResponse
+			unmarshalResponse(t, format, res, &list, &list.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, list.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			if assert.Len(t, list.Ocs.Data, 2) {
+				// newest first
+				assert.Equal(t, published[1].ID, list.Ocs.Data[0].NotificationID)
+				assert.Equal(t, published[0].ID, list.Ocs.Data[1].NotificationID)
+			}
+
+			res, err = sendRequestAs("GET", fmt.Sprintf("/%v/apps/notifications/api/v1/notifications/%v%v", ocsVersion, published[0].ID, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var single SingleNotificationResponse
+			unmarshalResponse(t, format, res, &single, &single.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.Equal(t, Notification{
+				NotificationID: published[0].ID,
+				App:            "files_sharing",
+				User:           einstein.Id.OpaqueId,
+				Subject:        "Marie shared Photos with you",
+				Link:           "https://localhost:9200/#/files/list/shared-with-me",
+			}, single.Ocs.Data)
+
+			res, err = sendRequestAs("DELETE", fmt.Sprintf("/%v/apps/notifications/api/v1/notifications/%v%v", ocsVersion, published[0].ID, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+
+			res, err = sendRequestAs("GET", fmt.Sprintf("/%v/apps/notifications/api/v1/notifications/%v%v", ocsVersion, published[0].ID, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var missing SingleNotificationResponse
+			unmarshalResponse(t, format, res, &missing, &missing.Ocs)
+			assertStatusCode(t, 404, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 998, Message: "notification not found"}, missing.Ocs.Meta)
+
+			res, err = sendRequestAs("DELETE", fmt.Sprintf("/%v/apps/notifications/api/v1/notifications%v", ocsVersion, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+
+			remaining, err := notifications.NewManager(storeService).List(context.Background(), einstein.Id.OpaqueId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Empty(t, remaining)
+			others, err := notifications.NewManager(storeService).List(context.Background(), richardID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Len(t, others, 1)
+		}
+	}
+}
+
+func TestPublishNotification(t *testing.T) {
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: adminID}, Username: "moss"}
+
+	testData := []struct {
+		user        *userpb.User
+		recipient   string
+		params      url.Values
+		statusCode  int
+		err         *Meta
+		description string
+	}{
+		{
+			user:        admin,
+			recipient:   einstein.Id.OpaqueId,
+			params:      url.Values{"app": {"files_sharing"}, "subject": {"Marie shared Photos with you"}, "message": {"Have a look"}},
+			description: "admin publishes a notification",
+		},
+		{
+			user:        admin,
+			recipient:   einstein.Username,
+			params:      url.Values{"app": {"files_sharing"}, "subject": {"Marie shared Photos with you"}, "message": {"Have a look"}},
+			description: "recipient given by username",
+		},
+		{
+			user:        einstein,
+			recipient:   richardID,
+			params:      url.Values{"subject": {"Hello"}},
+			statusCode:  403,
+			err:         &Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			description: "users cannot publish notifications",
+		},
+		{
+			user:        admin,
+			recipient:   einstein.Id.OpaqueId,
+			params:      url.Values{"message": {"Have a look"}},
+			statusCode:  400,
+			err:         &Meta{Status: "error", StatusCode: 400, Message: "please specify a subject"},
+			description: "missing subject",
+		},
+		{
+			user:        admin,
+			recipient:   "not-a-user",
+			params:      url.Values{"subject": {"Hello"}},
+			statusCode:  404,
+			err:         &Meta{Status: "error", StatusCode: 998, Message: "The requested user could not be found"},
+			description: "unknown recipient",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				storeService = newFakeStore()
+
+				res, err := sendRequestAs(
+					"POST",
+					fmt.Sprintf("/%v/apps/notifications/api/v1/admin_notifications/%v%v", ocsVersion, data.recipient, getFormatString(format)),
+					data.params.Encode(),
+					data.user,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var published SingleNotificationResponse
+				unmarshalResponse(t, format, res, &published, &published.Ocs)
+
+				list, err := notifications.NewManager(storeService).List(context.Background(), einstein.Id.OpaqueId)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if data.err != nil {
+					assertStatusCode(t, data.statusCode, res, ocsVersion)
+					assertResponseMeta(t, *data.err, published.Ocs.Meta)
+					assert.Empty(t, list, data.description)
+					continue
+				}
+
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.True(t, published.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				assert.Equal(t, Notification{
+					NotificationID: published.Ocs.Data.NotificationID,
+					App:            "files_sharing",
+					User:           einstein.Id.OpaqueId,
+					Subject:        "Marie shared Photos with you",
+					Message:        "Have a look",
+				}, published.Ocs.Data, data.description)
+				if assert.Len(t, list, 1, data.description) {
+					assert.Equal(t, published.Ocs.Data.NotificationID, list[0].ID, data.description)
+				}
+			}
+		}
+	}
+}
+
+// TestNotificationRecords checks the record format documented for extensions writing to the store directly
+func TestNotificationRecords(t *testing.T) {
+	storeService = newFakeStore()
+	value, err := json.Marshal(map[string]string{
+		"id":       "b1f74ec4-dd7e-11ea-a5e8-fb7a3d5c8fc1",
+		"app":      "files_sharing",
+		"user":     einstein.Id.OpaqueId,
+		"datetime": "2020-09-01T12:00:00Z",
+		"subject":  "Marie shared Photos with you",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storeService.Write(context.Background(), &storepb.WriteRequest{
+		Options: &storepb.WriteOptions{Database: notifications.Database, Table: notifications.Table},
+		Record: &storepb.Record{
+			Key:   einstein.Id.OpaqueId + "/b1f74ec4-dd7e-11ea-a5e8-fb7a3d5c8fc1",
+			Value: value,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			res, err := sendRequestAs("GET", fmt.Sprintf("/%v/apps/notifications/api/v1/notifications/b1f74ec4-dd7e-11ea-a5e8-fb7a3d5c8fc1%v", ocsVersion, getFormatString(format)), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var single SingleNotificationResponse
+			unmarshalResponse(t, format, res, &single, &single.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.Equal(t, Notification{
+				NotificationID: "b1f74ec4-dd7e-11ea-a5e8-fb7a3d5c8fc1",
+				App:            "files_sharing",
+				User:           einstein.Id.OpaqueId,
+				Subject:        "Marie shared Photos with you",
+			}, single.Ocs.Data)
+		}
+	}
+}
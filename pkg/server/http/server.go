@@ -24,6 +24,7 @@ func Server(opts ...Option) (http.Service, error) {
 	handle := svc.NewService(
 		svc.Logger(options.Logger),
 		svc.Config(options.Config),
+		svc.Metrics(options.Metrics),
 		svc.Middleware(
 			middleware.RealIP,
 			middleware.RequestID,
@@ -42,7 +43,7 @@ func Server(opts ...Option) (http.Service, error) {
 
 	{
 		handle = svc.NewInstrument(handle, options.Metrics)
-		handle = svc.NewLogging(handle, options.Logger)
+		handle = svc.NewLogging(handle, options.Logger, options.Config.Log)
 		handle = svc.NewTracing(handle)
 	}
 
@@ -0,0 +1,1012 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/token/manager/jwt"
+	"github.com/cs3org/reva/pkg/user"
+	"github.com/owncloud/ocis-ocs/pkg/config"
+	svc "github.com/owncloud/ocis-ocs/pkg/service/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// gatewayClient is used by the service under test instead of a real reva gateway
+var gatewayClient = newFakeGateway()
+
+var einstein = &userpb.User{
+	Id:          &userpb.UserId{OpaqueId: "4c510ada-c86b-4815-8820-42cdf82c3d51"},
+	Username:    "einstein",
+	DisplayName: "Albert Einstein",
+}
+
+const (
+	richardID       = "932b4540-8d16-481e-8ef4-588e4b6b151c"
+	physicsLoversID = "262982c1-2362-4afa-bfdf-8cbfef64a06e"
+)
+
+// fakeGateway is an in-process fake of the reva gateway. It only implements the calls used by the share handlers,
+// calling any other method panics because of the nil embedded interface.
+type fakeGateway struct {
+	gateway.GatewayAPIClient
+
+	mu     sync.Mutex
+	files  map[string]*provider.ResourceInfo
+	shares map[string]*collaboration.Share
+	links  map[string]*link.PublicShare
+	nextID int
+
+	// quotaUnavailable makes GetQuota fail like an unreachable storage
+	quotaUnavailable bool
+	// metadata records the arbitrary metadata set on the home storage
+	metadata map[string]string
+	// metadataUnavailable makes SetArbitraryMetadata fail like an unreachable storage
+	metadataUnavailable bool
+	// failLinkUpdate makes UpdatePublicShare fail for updates of this type
+	failLinkUpdate link.UpdatePublicShareRequest_Update_Type
+}
+
+func newFakeGateway() *fakeGateway {
+	owner := &userpb.UserId{OpaqueId: einstein.Id.OpaqueId}
+	return &fakeGateway{
+		files: map[string]*provider.ResourceInfo{
+			"/home/Photos": {
+				Id:       &provider.ResourceId{StorageId: "storage", OpaqueId: "photos"},
+				Path:     "/home/Photos",
+				Type:     provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+				MimeType: "httpd/unix-directory",
+				Owner:    owner,
+			},
+			"/home/notes.txt": {
+				Id:       &provider.ResourceId{StorageId: "storage", OpaqueId: "notes"},
+				Path:     "/home/notes.txt",
+				Type:     provider.ResourceType_RESOURCE_TYPE_FILE,
+				MimeType: "text/plain",
+				Owner:    owner,
+			},
+		},
+		shares:   map[string]*collaboration.Share{},
+		links:    map[string]*link.PublicShare{},
+		metadata: map[string]string{},
+	}
+}
+
+func statusOK() *rpc.Status {
+	return &rpc.Status{Code: rpc.Code_CODE_OK}
+}
+
+func statusNotFound() *rpc.Status {
+	return &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND, Message: "not found"}
+}
+
+func (g *fakeGateway) Stat(ctx context.Context, in *provider.StatRequest, opts ...grpc.CallOption) (*provider.StatResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch spec := in.Ref.Spec.(type) {
+	case *provider.Reference_Path:
+		if info, ok := g.files[spec.Path]; ok {
+			return &provider.StatResponse{Status: statusOK(), Info: info}, nil
+		}
+	case *provider.Reference_Id:
+		for _, info := range g.files {
+			if info.Id.OpaqueId == spec.Id.OpaqueId {
+				return &provider.StatResponse{Status: statusOK(), Info: info}, nil
+			}
+		}
+	}
+	return &provider.StatResponse{Status: statusNotFound()}, nil
+}
+
+func (g *fakeGateway) GetQuota(ctx context.Context, in *gateway.GetQuotaRequest, opts ...grpc.CallOption) (*provider.GetQuotaResponse, error) {
+	if g.quotaUnavailable {
+		return &provider.GetQuotaResponse{Status: &rpc.Status{Code: rpc.Code_CODE_UNAVAILABLE, Message: "storage unreachable"}}, nil
+	}
+	return &provider.GetQuotaResponse{
+		Status:     statusOK(),
+		TotalBytes: 10000000000,
+		UsedBytes:  1800000000,
+	}, nil
+}
+
+func (g *fakeGateway) SetArbitraryMetadata(ctx context.Context, in *provider.SetArbitraryMetadataRequest, opts ...grpc.CallOption) (*provider.SetArbitraryMetadataResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.metadataUnavailable {
+		return &provider.SetArbitraryMetadataResponse{Status: &rpc.Status{Code: rpc.Code_CODE_UNAVAILABLE, Message: "storage unreachable"}}, nil
+	}
+
+	for k, v := range in.ArbitraryMetadata.Metadata {
+		g.metadata[k] = v
+	}
+	return &provider.SetArbitraryMetadataResponse{Status: statusOK()}, nil
+}
+
+func (g *fakeGateway) CreateShare(ctx context.Context, in *collaboration.CreateShareRequest, opts ...grpc.CallOption) (*collaboration.CreateShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	u, _ := user.ContextGetUser(ctx)
+	g.nextID++
+	s := &collaboration.Share{
+		Id:          &collaboration.ShareId{OpaqueId: fmt.Sprint(g.nextID)},
+		ResourceId:  in.ResourceInfo.Id,
+		Permissions: in.Grant.Permissions,
+		Grantee:     in.Grant.Grantee,
+		Owner:       in.ResourceInfo.Owner,
+		Creator:     u.GetId(),
+		Ctime:       &types.Timestamp{Seconds: 1600000000},
+	}
+	g.shares[s.Id.OpaqueId] = s
+	return &collaboration.CreateShareResponse{Status: statusOK(), Share: s}, nil
+}
+
+func (g *fakeGateway) GetShare(ctx context.Context, in *collaboration.GetShareRequest, opts ...grpc.CallOption) (*collaboration.GetShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if s, ok := g.shares[in.Ref.GetId().GetOpaqueId()]; ok {
+		return &collaboration.GetShareResponse{Status: statusOK(), Share: s}, nil
+	}
+	return &collaboration.GetShareResponse{Status: statusNotFound()}, nil
+}
+
+func (g *fakeGateway) ListShares(ctx context.Context, in *collaboration.ListSharesRequest, opts ...grpc.CallOption) (*collaboration.ListSharesResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	shares := []*collaboration.Share{}
+	for _, s := range g.shares {
+		matches := true
+		for _, f := range in.Filters {
+			if f.Type == collaboration.ListSharesRequest_Filter_TYPE_RESOURCE_ID && f.GetResourceId().GetOpaqueId() != s.ResourceId.OpaqueId {
+				matches = false
+			}
+		}
+		if matches {
+			shares = append(shares, s)
+		}
+	}
+	return &collaboration.ListSharesResponse{Status: statusOK(), Shares: shares}, nil
+}
+
+func (g *fakeGateway) UpdateShare(ctx context.Context, in *collaboration.UpdateShareRequest, opts ...grpc.CallOption) (*collaboration.UpdateShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s, ok := g.shares[in.Ref.GetId().GetOpaqueId()]
+	if !ok {
+		return &collaboration.UpdateShareResponse{Status: statusNotFound()}, nil
+	}
+	s.Permissions = in.Field.GetPermissions()
+	return &collaboration.UpdateShareResponse{Status: statusOK(), Share: s}, nil
+}
+
+func (g *fakeGateway) RemoveShare(ctx context.Context, in *collaboration.RemoveShareRequest, opts ...grpc.CallOption) (*collaboration.RemoveShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.shares[in.Ref.GetId().GetOpaqueId()]; !ok {
+		return &collaboration.RemoveShareResponse{Status: statusNotFound()}, nil
+	}
+	delete(g.shares, in.Ref.GetId().GetOpaqueId())
+	return &collaboration.RemoveShareResponse{Status: statusOK()}, nil
+}
+
+func (g *fakeGateway) CreatePublicShare(ctx context.Context, in *link.CreatePublicShareRequest, opts ...grpc.CallOption) (*link.CreatePublicShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	u, _ := user.ContextGetUser(ctx)
+	g.nextID++
+	s := &link.PublicShare{
+		Id:                &link.PublicShareId{OpaqueId: fmt.Sprint(g.nextID)},
+		Token:             fmt.Sprintf("token%d", g.nextID),
+		ResourceId:        in.ResourceInfo.Id,
+		Permissions:       in.Grant.Permissions,
+		Owner:             in.ResourceInfo.Owner,
+		Creator:           u.GetId(),
+		Ctime:             &types.Timestamp{Seconds: 1600000000},
+		PasswordProtected: in.Grant.Password != "",
+		Expiration:        in.Grant.Expiration,
+	}
+	g.links[s.Id.OpaqueId] = s
+	return &link.CreatePublicShareResponse{Status: statusOK(), Share: s}, nil
+}
+
+func (g *fakeGateway) GetPublicShare(ctx context.Context, in *link.GetPublicShareRequest, opts ...grpc.CallOption) (*link.GetPublicShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if s, ok := g.links[in.Ref.GetId().GetOpaqueId()]; ok {
+		return &link.GetPublicShareResponse{Status: statusOK(), Share: s}, nil
+	}
+	return &link.GetPublicShareResponse{Status: statusNotFound()}, nil
+}
+
+func (g *fakeGateway) ListPublicShares(ctx context.Context, in *link.ListPublicSharesRequest, opts ...grpc.CallOption) (*link.ListPublicSharesResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	shares := []*link.PublicShare{}
+	for _, s := range g.links {
+		matches := true
+		for _, f := range in.Filters {
+			if f.Type == link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID && f.GetResourceId().GetOpaqueId() != s.ResourceId.OpaqueId {
+				matches = false
+			}
+		}
+		if matches {
+			shares = append(shares, s)
+		}
+	}
+	return &link.ListPublicSharesResponse{Status: statusOK(), Share: shares}, nil
+}
+
+func (g *fakeGateway) UpdatePublicShare(ctx context.Context, in *link.UpdatePublicShareRequest, opts ...grpc.CallOption) (*link.UpdatePublicShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s, ok := g.links[in.Ref.GetId().GetOpaqueId()]
+	if !ok {
+		return &link.UpdatePublicShareResponse{Status: statusNotFound()}, nil
+	}
+	if g.failLinkUpdate != link.UpdatePublicShareRequest_Update_TYPE_INVALID && in.Update.Type == g.failLinkUpdate {
+		return &link.UpdatePublicShareResponse{Status: &rpc.Status{Code: rpc.Code_CODE_INTERNAL, Message: "update failed"}}, nil
+	}
+	switch in.Update.Type {
+	case link.UpdatePublicShareRequest_Update_TYPE_PERMISSIONS:
+		s.Permissions = in.Update.Grant.Permissions
+	case link.UpdatePublicShareRequest_Update_TYPE_PASSWORD:
+		s.PasswordProtected = in.Update.Grant.Password != ""
+	case link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION:
+		s.Expiration = in.Update.Grant.Expiration
+	case link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME:
+		s.DisplayName = in.Update.DisplayName
+	}
+	return &link.UpdatePublicShareResponse{Status: statusOK(), Share: s}, nil
+}
+
+func (g *fakeGateway) RemovePublicShare(ctx context.Context, in *link.RemovePublicShareRequest, opts ...grpc.CallOption) (*link.RemovePublicShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.links[in.Ref.GetId().GetOpaqueId()]; !ok {
+		return &link.RemovePublicShareResponse{Status: statusNotFound()}, nil
+	}
+	delete(g.links, in.Ref.GetId().GetOpaqueId())
+	return &link.RemovePublicShareResponse{Status: statusOK()}, nil
+}
+
+type Share struct {
+	ID          string `json:"id" xml:"id"`
+	ShareType   int    `json:"share_type" xml:"share_type"`
+	UIDOwner    string `json:"uid_owner" xml:"uid_owner"`
+	Permissions int    `json:"permissions" xml:"permissions"`
+	ShareWith   string `json:"share_with" xml:"share_with"`
+	Path        string `json:"path" xml:"path"`
+	ItemType    string `json:"item_type" xml:"item_type"`
+	FileTarget  string `json:"file_target" xml:"file_target"`
+	Token       string `json:"token" xml:"token"`
+	Name        string `json:"name" xml:"name"`
+	URL         string `json:"url" xml:"url"`
+	Expiration  string `json:"expiration" xml:"expiration"`
+}
+
+type SingleShareResponse struct {
+	Ocs struct {
+		Meta Meta  `json:"meta" xml:"meta"`
+		Data Share `json:"data" xml:"data"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+type ListSharesResponse struct {
+	Ocs struct {
+		Meta Meta    `json:"meta" xml:"meta"`
+		Data []Share `json:"data" xml:"data>element"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+// sendRequestAs sends a request authenticated with an access token for the given user
+func sendRequestAs(method, endpoint, body string, u *userpb.User) (*httptest.ResponseRecorder, error) {
+	return sendRequestTo(getService(), method, endpoint, body, u)
+}
+
+// sendRequestTo sends a request authenticated with an access token for the given user to the given service
+func sendRequestTo(service svc.Service, method, endpoint, body string, u *userpb.User) (*httptest.ResponseRecorder, error) {
+	token, err := mintToken(u)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("x-access-token", token)
+
+	rr := httptest.NewRecorder()
+	service.ServeHTTP(rr, req)
+
+	return rr, nil
+}
+
+// mintToken creates an access token for the given user
+func mintToken(u *userpb.User) (string, error) {
+	tokenManager, err := jwt.New(map[string]interface{}{
+		"secret":  jwtSecret,
+		"expires": int64(60),
+	})
+	if err != nil {
+		return "", err
+	}
+	return tokenManager.MintToken(context.Background(), u)
+}
+
+func unmarshalResponse(t *testing.T, format string, res *httptest.ResponseRecorder, response interface{}, ocs interface{}) {
+	if format == "json" {
+		if err := json.Unmarshal(res.Body.Bytes(), response); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		if err := xml.Unmarshal(res.Body.Bytes(), ocs); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateShare(t *testing.T) {
+	testData := []struct {
+		params      url.Values
+		expected    Share
+		statusCode  int
+		err         *Meta
+		description string
+	}{
+		{
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"0"}, "shareWith": {richardID}},
+			expected:    Share{ShareType: 0, Permissions: 31, ShareWith: richardID, Path: "/Photos", ItemType: "folder", FileTarget: "/Photos", UIDOwner: einstein.Id.OpaqueId},
+			description: "user share of a folder with default permissions",
+		},
+		{
+			params:      url.Values{"path": {"/notes.txt"}, "shareType": {"0"}, "shareWith": {richardID}, "permissions": {"31"}},
+			expected:    Share{ShareType: 0, Permissions: 19, ShareWith: richardID, Path: "/notes.txt", ItemType: "file", FileTarget: "/notes.txt", UIDOwner: einstein.Id.OpaqueId},
+			description: "create and delete permissions are dropped for files",
+		},
+		{
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"1"}, "shareWith": {physicsLoversID}, "permissions": {"1"}},
+			expected:    Share{ShareType: 1, Permissions: 1, ShareWith: physicsLoversID, Path: "/Photos", ItemType: "folder", FileTarget: "/Photos", UIDOwner: einstein.Id.OpaqueId},
+			description: "group share",
+		},
+		{
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"7"}, "shareWith": {richardID}},
+			statusCode:  400,
+			err:         &Meta{Status: "error", StatusCode: 400, Message: "unknown share type"},
+			description: "unknown share type",
+		},
+		{
+			params:      url.Values{"shareType": {"0"}, "shareWith": {richardID}},
+			statusCode:  400,
+			err:         &Meta{Status: "error", StatusCode: 400, Message: "please specify a file or folder path"},
+			description: "missing path",
+		},
+		{
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"0"}, "shareWith": {richardID}, "permissions": {"64"}},
+			statusCode:  400,
+			err:         &Meta{Status: "error", StatusCode: 400, Message: "permissions must be an integer between 1 and 31"},
+			description: "invalid permissions",
+		},
+		{
+			params:      url.Values{"path": {"/does-not-exist"}, "shareType": {"0"}, "shareWith": {richardID}},
+			statusCode:  404,
+			err:         &Meta{Status: "error", StatusCode: 998, Message: "wrong path, file/folder doesn't exist"},
+			description: "path does not exist",
+		},
+		{
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"0"}, "shareWith": {"no-such-user"}},
+			statusCode:  404,
+			err:         &Meta{Status: "error", StatusCode: 998, Message: "the share recipient could not be found"},
+			description: "unknown recipient",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				gatewayClient = newFakeGateway()
+
+				res, err := sendRequestAs(
+					"POST",
+					fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares%v", ocsVersion, getFormatString(format)),
+					data.params.Encode(),
+					einstein,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response SingleShareResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				if data.err == nil {
+					assertStatusCode(t, 200, res, ocsVersion)
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+					data.expected.ID = response.Ocs.Data.ID
+					assert.Equal(t, data.expected, response.Ocs.Data, data.description)
+				} else {
+					assertStatusCode(t, data.statusCode, res, ocsVersion)
+					assertResponseMeta(t, *data.err, response.Ocs.Meta)
+				}
+			}
+		}
+	}
+}
+
+func TestShareLifecycle(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			gatewayClient = newFakeGateway()
+			formatpart := getFormatString(format)
+
+			res, err := sendRequestAs(
+				"POST",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares%v", ocsVersion, formatpart),
+				url.Values{"path": {"/Photos"}, "shareType": {"0"}, "shareWith": {richardID}}.Encode(),
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var created SingleShareResponse
+			unmarshalResponse(t, format, res, &created, &created.Ocs)
+			assert.True(t, created.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			shareID := created.Ocs.Data.ID
+
+			// update the permissions to read only
+			res, err = sendRequestAs(
+				"PUT",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, shareID, formatpart),
+				"permissions=1",
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var updated SingleShareResponse
+			unmarshalResponse(t, format, res, &updated, &updated.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.Equal(t, 1, updated.Ocs.Data.Permissions)
+
+			// the share is listed for its path
+			res, err = sendRequestAs(
+				"GET",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares?format=%v&path=%v", ocsVersion, format, url.QueryEscape("/Photos")),
+				"",
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var listed ListSharesResponse
+			unmarshalResponse(t, format, res, &listed, &listed.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			if assert.Len(t, listed.Ocs.Data, 1) {
+				assert.Equal(t, shareID, listed.Ocs.Data[0].ID)
+				assert.Equal(t, 1, listed.Ocs.Data[0].Permissions)
+			}
+
+			// delete it
+			res, err = sendRequestAs(
+				"DELETE",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, shareID, formatpart),
+				"",
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deleted EmptyResponse
+			unmarshalResponse(t, format, res, &deleted, &deleted.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, deleted.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+
+			// and it is gone
+			res, err = sendRequestAs(
+				"GET",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, shareID, formatpart),
+				"",
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gone ListSharesResponse
+			unmarshalResponse(t, format, res, &gone, &gone.Ocs)
+			assertStatusCode(t, 404, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 998, Message: "share not found"}, gone.Ocs.Meta)
+		}
+	}
+}
+
+func TestUpdateFileSharePermissions(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			gatewayClient = newFakeGateway()
+			formatpart := getFormatString(format)
+
+			res, err := sendRequestAs(
+				"POST",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares%v", ocsVersion, formatpart),
+				url.Values{"path": {"/notes.txt"}, "shareType": {"0"}, "shareWith": {richardID}, "permissions": {"1"}}.Encode(),
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var created SingleShareResponse
+			unmarshalResponse(t, format, res, &created, &created.Ocs)
+			assert.True(t, created.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			shareID := created.Ocs.Data.ID
+
+			// create and delete permissions are dropped for files, like when creating the share
+			res, err = sendRequestAs(
+				"PUT",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, shareID, formatpart),
+				"permissions=31",
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var updated SingleShareResponse
+			unmarshalResponse(t, format, res, &updated, &updated.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.Equal(t, 19, updated.Ocs.Data.Permissions)
+			assert.Equal(t, "file", updated.Ocs.Data.ItemType)
+
+			gatewayClient.mu.Lock()
+			stored := gatewayClient.shares[shareID].GetPermissions().GetPermissions()
+			gatewayClient.mu.Unlock()
+			assert.False(t, stored.CreateContainer, "files cannot be shared with create permissions")
+			assert.False(t, stored.Delete, "files cannot be shared with delete permissions")
+
+			// updating a share that does not exist
+			res, err = sendRequestAs(
+				"PUT",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, "does-not-exist", formatpart),
+				"permissions=1",
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var missing SingleShareResponse
+			unmarshalResponse(t, format, res, &missing, &missing.Ocs)
+			assertStatusCode(t, 404, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 998, Message: "share not found"}, missing.Ocs.Meta)
+		}
+	}
+}
+
+func TestCreatePublicShare(t *testing.T) {
+	today := time.Now().UTC().Format("2006-01-02")
+	enforcingConfig := getConfig()
+	enforcingConfig.Capabilities.FilesSharing = &data.CapabilitiesFilesSharing{
+		Public: &data.CapabilitiesFilesSharingPublic{
+			Enabled: true,
+			Upload:  true,
+			Password: &data.CapabilitiesFilesSharingPublicPassword{
+				EnforcedFor: &data.CapabilitiesFilesSharingPublicPasswordEnforcedFor{
+					ReadWrite: true,
+				},
+			},
+			ExpireDate: &data.CapabilitiesFilesSharingPublicExpireDate{
+				Enabled:  true,
+				Days:     7,
+				Enforced: true,
+			},
+		},
+	}
+
+	testData := []struct {
+		config      *config.Config
+		params      url.Values
+		expected    Share
+		statusCode  int
+		err         *Meta
+		description string
+	}{
+		{
+			config:      getConfig(),
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"3"}, "name": {"Holiday"}},
+			expected:    Share{ShareType: 3, Permissions: 1, Path: "/Photos", ItemType: "folder", FileTarget: "/Photos", UIDOwner: einstein.Id.OpaqueId, Name: "Holiday"},
+			description: "read only link with a name",
+		},
+		{
+			config:      getConfig(),
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"3"}, "permissions": {"4"}, "password": {"secret"}},
+			expected:    Share{ShareType: 3, Permissions: 4, ShareWith: "***redacted***", Path: "/Photos", ItemType: "folder", FileTarget: "/Photos", UIDOwner: einstein.Id.OpaqueId},
+			description: "upload only link with a password",
+		},
+		{
+			config:      getConfig(),
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"3"}, "expireDate": {today}},
+			expected:    Share{ShareType: 3, Permissions: 1, Path: "/Photos", ItemType: "folder", FileTarget: "/Photos", UIDOwner: einstein.Id.OpaqueId, Expiration: today + " 23:59:59"},
+			description: "link expiring at the end of today",
+		},
+		{
+			config:      getConfig(),
+			params:      url.Values{"path": {"/notes.txt"}, "shareType": {"3"}, "permissions": {"15"}},
+			statusCode:  400,
+			err:         &Meta{Status: "error", StatusCode: 400, Message: "public upload is only possible for public shared folders"},
+			description: "upload to a file",
+		},
+		{
+			config:      getConfig(),
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"3"}, "expireDate": {"2000-01-01"}},
+			statusCode:  400,
+			err:         &Meta{Status: "error", StatusCode: 400, Message: "expiration date is in the past"},
+			description: "expiration in the past",
+		},
+		{
+			config:      enforcingConfig,
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"3"}, "permissions": {"15"}},
+			statusCode:  403,
+			err:         &Meta{Status: "error", StatusCode: 403, Message: "a password is required for this kind of link"},
+			description: "password enforced for read/write links",
+		},
+		{
+			config:      enforcingConfig,
+			params:      url.Values{"path": {"/Photos"}, "shareType": {"3"}, "expireDate": {"2999-01-01"}},
+			statusCode:  400,
+			err:         &Meta{Status: "error", StatusCode: 400, Message: "cannot set expiration date more than 7 days in the future"},
+			description: "expiration beyond the enforced maximum",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				gatewayClient = newFakeGateway()
+
+				res, err := sendRequestTo(
+					getServiceWithConfig(data.config),
+					"POST",
+					fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares%v", ocsVersion, getFormatString(format)),
+					data.params.Encode(),
+					einstein,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response SingleShareResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				if data.err == nil {
+					assertStatusCode(t, 200, res, ocsVersion)
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+					assert.NotEmpty(t, response.Ocs.Data.Token, data.description)
+					assert.Equal(t, "https://localhost:9200/#/s/"+response.Ocs.Data.Token, response.Ocs.Data.URL, data.description)
+					data.expected.ID = response.Ocs.Data.ID
+					data.expected.Token = response.Ocs.Data.Token
+					data.expected.URL = response.Ocs.Data.URL
+					assert.Equal(t, data.expected, response.Ocs.Data, data.description)
+				} else {
+					assertStatusCode(t, data.statusCode, res, ocsVersion)
+					assertResponseMeta(t, *data.err, response.Ocs.Meta)
+				}
+			}
+		}
+	}
+}
+
+func TestUpdatePublicShare(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			gatewayClient = newFakeGateway()
+			formatpart := getFormatString(format)
+
+			res, err := sendRequestAs(
+				"POST",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares%v", ocsVersion, formatpart),
+				url.Values{"path": {"/Photos"}, "shareType": {"3"}, "password": {"secret"}}.Encode(),
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var created SingleShareResponse
+			unmarshalResponse(t, format, res, &created, &created.Ocs)
+			assert.True(t, created.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			shareID := created.Ocs.Data.ID
+
+			// remove the password, make it writable, rename it and let it expire
+			res, err = sendRequestAs(
+				"PUT",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, shareID, formatpart),
+				url.Values{"password": {""}, "permissions": {"15"}, "name": {"Upload here"}, "expireDate": {"2999-01-01"}}.Encode(),
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var updated SingleShareResponse
+			unmarshalResponse(t, format, res, &updated, &updated.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, updated.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			assert.Equal(t, 15, updated.Ocs.Data.Permissions)
+			assert.Equal(t, "", updated.Ocs.Data.ShareWith)
+			assert.Equal(t, "Upload here", updated.Ocs.Data.Name)
+			assert.Equal(t, "2999-01-01 23:59:59", updated.Ocs.Data.Expiration, "links expire at the end of the day")
+
+			// public links are listed together with the other shares
+			res, err = sendRequestAs(
+				"GET",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares%v", ocsVersion, formatpart),
+				"",
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var listed ListSharesResponse
+			unmarshalResponse(t, format, res, &listed, &listed.Ocs)
+			if assert.Len(t, listed.Ocs.Data, 1) {
+				assert.Equal(t, 3, listed.Ocs.Data[0].ShareType)
+			}
+
+			// delete it
+			res, err = sendRequestAs(
+				"DELETE",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, shareID, formatpart),
+				"",
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+			gatewayClient.mu.Lock()
+			assert.Empty(t, gatewayClient.links)
+			gatewayClient.mu.Unlock()
+		}
+	}
+}
+
+func TestPublicShareUpdateFailures(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			gatewayClient = newFakeGateway()
+			formatpart := getFormatString(format)
+			endpoint := fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares%v", ocsVersion, formatpart)
+
+			// a link that cannot be named is removed again
+			gatewayClient.failLinkUpdate = link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME
+			res, err := sendRequestAs("POST", endpoint, url.Values{"path": {"/Photos"}, "shareType": {"3"}, "name": {"Holiday"}}.Encode(), einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var failed SingleShareResponse
+			unmarshalResponse(t, format, res, &failed, &failed.Ocs)
+			assertStatusCode(t, 500, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 996, Message: "could not update public link"}, failed.Ocs.Meta)
+			gatewayClient.mu.Lock()
+			assert.Empty(t, gatewayClient.links, "the unnamed link is expected to be removed")
+			gatewayClient.mu.Unlock()
+
+			gatewayClient.failLinkUpdate = link.UpdatePublicShareRequest_Update_TYPE_INVALID
+			res, err = sendRequestAs("POST", endpoint, url.Values{"path": {"/Photos"}, "shareType": {"3"}, "name": {"Holiday"}}.Encode(), einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var created SingleShareResponse
+			unmarshalResponse(t, format, res, &created, &created.Ocs)
+			assert.True(t, created.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			shareID := created.Ocs.Data.ID
+
+			// if the password cannot be set the other changes are reverted
+			gatewayClient.failLinkUpdate = link.UpdatePublicShareRequest_Update_TYPE_PASSWORD
+			res, err = sendRequestAs(
+				"PUT",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, shareID, formatpart),
+				url.Values{"password": {"secret"}, "permissions": {"15"}, "name": {"Upload here"}, "expireDate": {"2999-01-01"}}.Encode(),
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			failed = SingleShareResponse{}
+			unmarshalResponse(t, format, res, &failed, &failed.Ocs)
+			assertStatusCode(t, 500, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 996, Message: "could not update public link"}, failed.Ocs.Meta)
+
+			// invalid parameters are rejected before anything is changed
+			gatewayClient.failLinkUpdate = link.UpdatePublicShareRequest_Update_TYPE_INVALID
+			res, err = sendRequestAs(
+				"PUT",
+				fmt.Sprintf("/%v/apps/files_sharing/api/v1/shares/%v%v", ocsVersion, shareID, formatpart),
+				url.Values{"name": {"Renamed"}, "expireDate": {"2000-01-01"}}.Encode(),
+				einstein,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			failed = SingleShareResponse{}
+			unmarshalResponse(t, format, res, &failed, &failed.Ocs)
+			assertStatusCode(t, 400, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 400, Message: "expiration date is in the past"}, failed.Ocs.Meta)
+
+			gatewayClient.mu.Lock()
+			if l, ok := gatewayClient.links[shareID]; assert.True(t, ok, "the link is expected to be kept") {
+				assert.Equal(t, "Holiday", l.DisplayName)
+				assert.Nil(t, l.Expiration)
+				assert.False(t, l.PasswordProtected)
+				assert.False(t, l.Permissions.GetPermissions().GetMove(), "the link is expected to stay read only")
+			}
+			gatewayClient.mu.Unlock()
+		}
+	}
+}
+
+type Sharee struct {
+	Label string `json:"label" xml:"label"`
+	Value struct {
+		ShareType int    `json:"shareType" xml:"shareType"`
+		ShareWith string `json:"shareWith" xml:"shareWith"`
+	} `json:"value" xml:"value"`
+}
+
+type ShareesResponse struct {
+	Ocs struct {
+		Meta Meta `json:"meta" xml:"meta"`
+		Data struct {
+			Exact struct {
+				Users  []Sharee `json:"users" xml:"users>element"`
+				Groups []Sharee `json:"groups" xml:"groups>element"`
+			} `json:"exact" xml:"exact"`
+			Users  []Sharee `json:"users" xml:"users>element"`
+			Groups []Sharee `json:"groups" xml:"groups>element"`
+		} `json:"data" xml:"data"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+func shareWith(sharees []Sharee) []string {
+	ids := []string{}
+	for _, s := range sharees {
+		ids = append(ids, s.Value.ShareWith)
+	}
+	return ids
+}
+
+func TestListSharees(t *testing.T) {
+	const marieID = "f7fbf8c8-139b-4376-b307-cf0a8c2d0d9c"
+
+	testData := []struct {
+		query       string
+		exactUsers  []string
+		users       []string
+		exactGroups []string
+		groups      []string
+		description string
+	}{
+		{
+			query:       "search=rich",
+			exactUsers:  []string{},
+			users:       []string{richardID},
+			exactGroups: []string{},
+			groups:      []string{},
+			description: "search by the beginning of the username",
+		},
+		{
+			query:       "search=MARIE",
+			exactUsers:  []string{marieID},
+			users:       []string{},
+			exactGroups: []string{},
+			groups:      []string{},
+			description: "exact match ignoring case",
+		},
+		{
+			query:       "search=physics",
+			exactUsers:  []string{},
+			users:       []string{},
+			exactGroups: []string{},
+			groups:      []string{physicsLoversID},
+			description: "search a group of the current user",
+		},
+		{
+			query:       "search=radium-lovers",
+			exactUsers:  []string{},
+			users:       []string{},
+			exactGroups: []string{},
+			groups:      []string{},
+			description: "groups of other users are not listed",
+		},
+		{
+			query:       "search=einstein",
+			exactUsers:  []string{},
+			users:       []string{},
+			exactGroups: []string{},
+			groups:      []string{},
+			description: "the current user is not listed",
+		},
+		{
+			query:       "search=r",
+			exactUsers:  []string{},
+			users:       []string{},
+			exactGroups: []string{},
+			groups:      []string{},
+			description: "search shorter than the minimum search length",
+		},
+		{
+			query:       "search=ri&perPage=1&page=1",
+			exactUsers:  []string{},
+			users:       []string{richardID},
+			exactGroups: []string{},
+			groups:      []string{},
+			description: "first page of the results",
+		},
+		{
+			query:       "search=ri&perPage=1&page=2",
+			exactUsers:  []string{},
+			users:       []string{},
+			exactGroups: []string{},
+			groups:      []string{},
+			description: "page after the last result",
+		},
+		{
+			query:       "search=ri&perPage=4611686018427387904&page=3",
+			exactUsers:  []string{},
+			users:       []string{},
+			exactGroups: []string{},
+			groups:      []string{},
+			description: "page offset overflowing an int",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				res, err := sendRequestAs(
+					"GET",
+					fmt.Sprintf("/%v/apps/files_sharing/api/v1/sharees?%v&format=%v", ocsVersion, data.query, format),
+					"",
+					einstein,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response ShareesResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				assert.Equal(t, data.exactUsers, shareWith(response.Ocs.Data.Exact.Users), data.description)
+				assert.Equal(t, data.users, shareWith(response.Ocs.Data.Users), data.description)
+				assert.Equal(t, data.exactGroups, shareWith(response.Ocs.Data.Exact.Groups), data.description)
+				assert.Equal(t, data.groups, shareWith(response.Ocs.Data.Groups), data.description)
+			}
+		}
+	}
+}
@@ -1,25 +1,36 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/owncloud/ocis-ocs/pkg/config"
 	svc "github.com/owncloud/ocis-ocs/pkg/service/v0"
 	ocisLog "github.com/owncloud/ocis-pkg/v2/log"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
 
 	"github.com/owncloud/ocis-pkg/v2/service/grpc"
 
@@ -34,7 +45,9 @@ import (
 
 var service = grpc.Service{}
 
-var mockedRoleAssignment = map[string]string{}
+var mockedRoleAssignment = map[string]string{
+	adminID: adminRoleID,
+}
 
 var ocsVersions = []string{"v1.php", "v2.php"}
 
@@ -42,6 +55,40 @@ var formats = []string{"json", "xml"}
 
 const dataPath = "./accounts-store"
 
+const jwtSecret = "HELLO-secret"
+
+const (
+	// adminID is the id of the user the "admin:admin" credentials of sendRequest authenticate as
+	adminID     = "058bff95-6708-4fe5-91e4-9ea3d377588b"
+	adminRoleID = "71881883-1768-46bd-a24d-a356a2afdf7f"
+	// adminPermissionID is the account management permission of the admin role
+	adminPermissionID = "8e587774-d929-4215-910b-a317b1e80f73"
+)
+
+// mockedRoles holds the roles of the role service mock, roles without the admin permission only grant user access
+var mockedRoles = map[string]*settings.Bundle{
+	adminRoleID: adminRole(adminRoleID),
+}
+
+// adminRole returns a role that has the permission to manage all accounts
+func adminRole(id string) *settings.Bundle {
+	return &settings.Bundle{
+		Id:   id,
+		Type: settings.Bundle_TYPE_ROLE,
+		Settings: []*settings.Setting{
+			{
+				Id: adminPermissionID,
+				Value: &settings.Setting_PermissionValue{
+					PermissionValue: &settings.Permission{
+						Operation:  settings.Permission_OPERATION_READWRITE,
+						Constraint: settings.Permission_CONSTRAINT_ALL,
+					},
+				},
+			},
+		},
+	}
+}
+
 var DefaultUsers = []string{
 	"4c510ada-c86b-4815-8820-42cdf82c3d51",
 	"820ba2a1-3f54-4538-80a4-2d73007e30bf",
@@ -121,6 +168,12 @@ type Meta struct {
 	Message    string `json:"message" xml:"message"`
 }
 
+type PaginatedMeta struct {
+	Meta
+	TotalItems   string `json:"totalitems" xml:"totalitems"`
+	ItemsPerPage string `json:"itemsperpage" xml:"itemsperpage"`
+}
+
 func (m *Meta) Success(ocsVersion string) bool {
 	if !(ocsVersion == "v1.php" || ocsVersion == "v2.php") {
 		return false
@@ -235,6 +288,29 @@ func buildRoleServiceMock() settings.RoleService {
 				},
 			}, nil
 		},
+		ListRoleAssignmentsFunc: func(ctx context.Context, req *settings.ListRoleAssignmentsRequest, opts ...client.CallOption) (res *settings.ListRoleAssignmentsResponse, err error) {
+			assignments := []*settings.UserRoleAssignment{}
+			if roleID, ok := mockedRoleAssignment[req.AccountUuid]; ok {
+				assignments = append(assignments, &settings.UserRoleAssignment{
+					AccountUuid: req.AccountUuid,
+					RoleId:      roleID,
+				})
+			}
+			return &settings.ListRoleAssignmentsResponse{
+				Assignments: assignments,
+			}, nil
+		},
+		ListRolesFunc: func(ctx context.Context, req *settings.ListBundlesRequest, opts ...client.CallOption) (res *settings.ListBundlesResponse, err error) {
+			roles := []*settings.Bundle{}
+			for _, id := range req.BundleIds {
+				if role, ok := mockedRoles[id]; ok {
+					roles = append(roles, role)
+				}
+			}
+			return &settings.ListBundlesResponse{
+				Bundles: roles,
+			}, nil
+		},
 	}
 }
 
@@ -317,6 +393,16 @@ func sendRequest(method, endpoint, body, auth string) (*httptest.ResponseRecorde
 	if auth != "" {
 		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
 	}
+	if auth == "admin:admin" {
+		t, err := mintToken(&userpb.User{
+			Id:       &userpb.UserId{OpaqueId: adminID},
+			Username: "moss",
+		})
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-access-token", t)
+	}
 
 	rr := httptest.NewRecorder()
 
@@ -327,30 +413,70 @@ func sendRequest(method, endpoint, body, auth string) (*httptest.ResponseRecorde
 }
 
 func getService() svc.Service {
-	c := &config.Config{
+	return getServiceWithConfig(getConfig())
+}
+
+func getConfig() *config.Config {
+	return &config.Config{
 		HTTP: config.HTTP{
 			Root:      "/",
 			Addr:      "localhost:9110",
 			Namespace: "com.owncloud.web",
 		},
 		TokenManager: config.TokenManager{
-			JWTSecret: "HELLO-secret",
+			JWTSecret: jwtSecret,
+		},
+		Reva: config.Reva{
+			HomeNamespace: "/home",
+		},
+		Sharing: config.Sharing{
+			PublicURL: "https://localhost:9200",
+		},
+		Authorization: config.Authorization{
+			AdminPermission: adminPermissionID,
 		},
 		Log: config.Log{
 			Level: "debug",
 		},
 	}
+}
 
+func getServiceWithConfig(c *config.Config) svc.Service {
 	var logger ocisLog.Logger
 
 	svc := svc.NewService(
 		svc.Logger(logger),
 		svc.Config(c),
+		svc.GatewayClient(gatewayClient),
+		svc.StoreService(storeService),
+		svc.RoleService(buildRoleServiceMock()),
 	)
 
 	return svc
 }
 
+// getBasicAuthConfig returns a config accepting http basic credentials
+func getBasicAuthConfig() *config.Config {
+	c := getConfig()
+	c.Authentication.BasicAuth = true
+	return c
+}
+
+// sendBasicAuthRequest sends a request authenticated only with http basic credentials
+func sendBasicAuthRequest(service svc.Service, method, endpoint, body, auth string) (*httptest.ResponseRecorder, error) {
+	req, err := http.NewRequest(method, endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
+
+	rr := httptest.NewRecorder()
+	service.ServeHTTP(rr, req)
+
+	return rr, nil
+}
+
 func createUser(u User) error {
 	_, err := sendRequest(
 		"POST",
@@ -708,6 +834,63 @@ func TestGetUsersDefaultUsers(t *testing.T) {
 	}
 }
 
+func TestListPagination(t *testing.T) {
+	testData := []struct {
+		endpoint    string
+		description string
+	}{
+		{endpoint: "cloud/users", description: "users"},
+		{endpoint: "cloud/groups", description: "groups"},
+		{endpoint: "cloud/groups/509a9dcd-bb37-4f4f-a01a-19dca27d9cfa", description: "group members"},
+	}
+
+	// list returns the ids of a user or group listing
+	list := func(t *testing.T, endpoint string) (*httptest.ResponseRecorder, PaginatedMeta, []string) {
+		res, err := sendRequest("GET", endpoint, "", "admin:admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Ocs struct {
+				Meta PaginatedMeta `json:"meta"`
+				Data struct {
+					Users  []string `json:"users"`
+					Groups []string `json:"groups"`
+				} `json:"data"`
+			} `json:"ocs"`
+		}
+		if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return res, response.Ocs.Meta, append(response.Ocs.Data.Users, response.Ocs.Data.Groups...)
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, data := range testData {
+			_, meta, ids := list(t, fmt.Sprintf("/%v/%v?format=json", ocsVersion, data.endpoint))
+			if len(ids) < 3 {
+				t.Fatalf("%v: expected at least 3 items, got %v", data.description, len(ids))
+			}
+			assert.True(t, sort.StringsAreSorted(ids), "%v: the items are expected to be sorted", data.description)
+			assert.Equal(t, strconv.Itoa(len(ids)), meta.TotalItems, data.description)
+
+			res, meta, page := list(t, fmt.Sprintf("/%v/%v?format=json&limit=2&offset=1", ocsVersion, data.endpoint))
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.Equal(t, ids[1:3], page, data.description)
+			assert.Equal(t, strconv.Itoa(len(ids)), meta.TotalItems, data.description)
+			assert.Equal(t, "2", meta.ItemsPerPage, data.description)
+
+			res, _, page = list(t, fmt.Sprintf("/%v/%v?format=json&limit=9223372036854775807&offset=1", ocsVersion, data.endpoint))
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.Equal(t, ids[1:], page, "%v: a limit overflowing the offset returns the remaining items", data.description)
+
+			res, meta, _ = list(t, fmt.Sprintf("/%v/%v?format=json&limit=-1", ocsVersion, data.endpoint))
+			assertStatusCode(t, 400, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 400, Message: "invalid limit"}, meta.Meta)
+		}
+	}
+}
+
 func TestGetUser(t *testing.T) {
 	users := []User{
 		{
@@ -1136,8 +1319,6 @@ func TestUpdateUser(t *testing.T) {
 	}
 }
 
-// This is a bug demonstration test for endpoint '/cloud/user'
-// Link to the issue: https://github.com/owncloud/ocis-ocs/issues/52
 func TestGetSingleUser(t *testing.T) {
 	user := User{
 		Enabled:     "true",
@@ -1147,6 +1328,7 @@ func TestGetSingleUser(t *testing.T) {
 		Displayname: "Ernest RutherFord",
 		Password:    "password",
 	}
+	service := getServiceWithConfig(getBasicAuthConfig())
 
 	for _, ocsVersion := range ocsVersions {
 		for _, format := range formats {
@@ -1156,7 +1338,8 @@ func TestGetSingleUser(t *testing.T) {
 			}
 
 			formatpart := getFormatString(format)
-			res, err := sendRequest(
+			res, err := sendBasicAuthRequest(
+				service,
 				"GET",
 				fmt.Sprintf("/%v/cloud/user%v", ocsVersion, formatpart),
 				"",
@@ -1167,35 +1350,18 @@ func TestGetSingleUser(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			var response EmptyResponse
-
-			if format == "json" {
-				if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
-					log.Println(err)
-					t.Fatal(err)
-				}
-			} else {
-				if err := xml.Unmarshal(res.Body.Bytes(), &response.Ocs); err != nil {
-					t.Fatal(err)
-				}
-			}
+			var response SingleUserResponse
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
 
-			assertStatusCode(t, 400, res, ocsVersion)
-			assert.False(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be a failure but was not")
-			assertResponseMeta(t, Meta{
-				Status:     "error",
-				StatusCode: 400,
-				Message:    "missing user in context",
-			}, response.Ocs.Meta)
-			assert.Empty(t, response.Ocs.Data)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			assert.Equal(t, user.ID, response.Ocs.Data.ID)
+			assert.Equal(t, user.Email, response.Ocs.Data.Email)
 			cleanUp(t)
 		}
 	}
 }
 
-// This is a bug demonstration test for endpoint '/cloud/user'
-// Link to the issue: https://github.com/owncloud/ocis-ocs/issues/53
-
 func TestGetUserSigningKey(t *testing.T) {
 	user := User{
 		Enabled:     "true",
@@ -1205,6 +1371,7 @@ func TestGetUserSigningKey(t *testing.T) {
 		Displayname: "Ernest RutherFord",
 		Password:    "password",
 	}
+	service := getServiceWithConfig(getBasicAuthConfig())
 
 	for _, ocsVersion := range ocsVersions {
 		for _, format := range formats {
@@ -1214,7 +1381,8 @@ func TestGetUserSigningKey(t *testing.T) {
 			}
 
 			formatpart := getFormatString(format)
-			res, err := sendRequest(
+			res, err := sendBasicAuthRequest(
+				service,
 				"GET",
 				fmt.Sprintf("/%v/cloud/user/signing-key%v", ocsVersion, formatpart),
 				"",
@@ -1226,26 +1394,84 @@ func TestGetUserSigningKey(t *testing.T) {
 			}
 
 			var response EmptyResponse
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
 
-			if format == "json" {
-				if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
-					log.Println(err)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			cleanUp(t)
+		}
+	}
+}
+
+func TestBasicAuthFailures(t *testing.T) {
+	user := User{
+		Enabled:     "true",
+		Username:    "rutherford",
+		ID:          "rutherford",
+		Email:       "rutherford@example.com",
+		Displayname: "Ernest RutherFord",
+		Password:    "password",
+	}
+
+	testData := []struct {
+		service     svc.Service
+		auth        string
+		meta        Meta
+		challenge   bool
+		description string
+	}{
+		{
+			service:     getServiceWithConfig(getBasicAuthConfig()),
+			auth:        "rutherford:wrong",
+			meta:        Meta{Status: "error", StatusCode: 997, Message: "Unauthorised"},
+			challenge:   true,
+			description: "wrong password",
+		},
+		{
+			service:     getServiceWithConfig(getBasicAuthConfig()),
+			auth:        "bohr:password",
+			meta:        Meta{Status: "error", StatusCode: 997, Message: "Unauthorised"},
+			challenge:   true,
+			description: "unknown user",
+		},
+		{
+			service:     getService(),
+			auth:        "rutherford:password",
+			meta:        Meta{Status: "error", StatusCode: 997, Message: "missing user in context"},
+			description: "basic auth disabled",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			err := createUser(user)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, data := range testData {
+				res, err := sendBasicAuthRequest(
+					data.service,
+					"GET",
+					fmt.Sprintf("/%v/cloud/user%v", ocsVersion, getFormatString(format)),
+					"",
+					data.auth,
+				)
+				if err != nil {
 					t.Fatal(err)
 				}
-			} else {
-				if err := xml.Unmarshal(res.Body.Bytes(), &response.Ocs); err != nil {
-					t.Fatal(err)
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, 401, res, ocsVersion)
+				assertResponseMeta(t, data.meta, response.Ocs.Meta)
+				if data.challenge {
+					assert.Contains(t, res.Header().Get("WWW-Authenticate"), "Basic", data.description)
+				} else {
+					assert.Empty(t, res.Header().Get("WWW-Authenticate"), data.description)
 				}
 			}
-
-			assertStatusCode(t, 400, res, ocsVersion)
-			assert.False(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be a failure but was not")
-			assertResponseMeta(t, Meta{
-				Status:     "error",
-				StatusCode: 400,
-				Message:    "missing user in context",
-			}, response.Ocs.Meta)
-			assert.Empty(t, response.Ocs.Data)
 			cleanUp(t)
 		}
 	}
@@ -1583,7 +1809,6 @@ func TestAddUsersToGroupInvalidGroup(t *testing.T) {
 	cleanUp(t)
 }
 
-// Issue: https://github.com/owncloud/ocis-ocs/issues/57 - cannot remove user from group
 func TestRemoveUserFromGroup(t *testing.T) {
 	user := User{
 		Enabled:     "true",
@@ -1638,12 +1863,8 @@ func TestRemoveUserFromGroup(t *testing.T) {
 				}
 			}
 
-			assertStatusCode(t, 500, res, ocsVersion)
-			assertResponseMeta(t, Meta{
-				"error",
-				996,
-				"{\"id\":\".\",\"code\":500,\"detail\":\"could not clean up group id: invalid id .\",\"status\":\"Internal Server Error\"}",
-			}, response.Ocs.Meta)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
 			assert.Empty(t, response.Ocs.Data)
 
 			// Check the users are correctly added to group
@@ -1661,9 +1882,7 @@ func TestRemoveUserFromGroup(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			// Change this line once the issue is fixed
-			// assert.NotContains(t, grpResponse.Ocs.Data.Groups, groups[0])
-			assert.Contains(t, grpResponse.Ocs.Data.Groups, groups[0])
+			assert.NotContains(t, grpResponse.Ocs.Data.Groups, groups[0])
 			assert.Contains(t, grpResponse.Ocs.Data.Groups, groups[1])
 			assert.Contains(t, grpResponse.Ocs.Data.Groups, groups[2])
 			cleanUp(t)
@@ -1671,6 +1890,69 @@ func TestRemoveUserFromGroup(t *testing.T) {
 	}
 }
 
+type GetCapabilitiesResponse struct {
+	Ocs struct {
+		Meta Meta `json:"meta" xml:"meta"`
+		Data struct {
+			Capabilities struct {
+				Core struct {
+					PollInterval int    `json:"pollinterval" xml:"pollinterval"`
+					WebdavRoot   string `json:"webdav-root" xml:"webdav-root"`
+				} `json:"core" xml:"core"`
+				FilesSharing struct {
+					SearchMinLength int `json:"search_min_length" xml:"search_min_length"`
+				} `json:"files_sharing" xml:"files_sharing"`
+				PasswordPolicy struct {
+					MinCharacters int `json:"min_characters" xml:"min_characters"`
+					MinDigits     int `json:"min_digits" xml:"min_digits"`
+				} `json:"password_policy" xml:"password_policy"`
+			} `json:"capabilities" xml:"capabilities"`
+			Version struct {
+				String  string `json:"string" xml:"string"`
+				Edition string `json:"edition" xml:"edition"`
+			} `json:"version" xml:"version"`
+		} `json:"data" xml:"data"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+func TestGetCapabilities(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			formatpart := getFormatString(format)
+			res, err := sendRequest(
+				"GET",
+				fmt.Sprintf("/%v/cloud/capabilities%v", ocsVersion, formatpart),
+				"",
+				"admin:admin",
+			)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var response GetCapabilitiesResponse
+
+			if format == "json" {
+				if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				if err := xml.Unmarshal(res.Body.Bytes(), &response.Ocs); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			assert.Equal(t, 60, response.Ocs.Data.Capabilities.Core.PollInterval)
+			assert.Equal(t, "remote.php/webdav", response.Ocs.Data.Capabilities.Core.WebdavRoot)
+			assert.Equal(t, 2, response.Ocs.Data.Capabilities.FilesSharing.SearchMinLength)
+			assert.Equal(t, "community", response.Ocs.Data.Version.Edition)
+			assert.NotEmpty(t, response.Ocs.Data.Version.String)
+		}
+	}
+}
+
 type mockClient struct{}
 
 func (c mockClient) Init(option ...client.Option) error {
@@ -1704,3 +1986,1651 @@ func (c mockClient) Publish(ctx context.Context, msg client.Message, opts ...cli
 func (c mockClient) String() string {
 	return "ClientMock"
 }
+
+func TestProvisioningAuthorization(t *testing.T) {
+	testData := []struct {
+		method      string
+		endpoint    string
+		user        *userpb.User
+		status      int
+		err         *Meta
+		description string
+	}{
+		{
+			method:      "GET",
+			endpoint:    "cloud/users",
+			status:      401,
+			err:         &Meta{Status: "error", StatusCode: 997, Message: "missing user in context"},
+			description: "unauthenticated user listing",
+		},
+		{
+			method:      "GET",
+			endpoint:    "cloud/users/" + einstein.Id.OpaqueId,
+			status:      401,
+			err:         &Meta{Status: "error", StatusCode: 997, Message: "missing user in context"},
+			description: "unauthenticated user details",
+		},
+		{
+			method:      "GET",
+			endpoint:    "cloud/users",
+			user:        einstein,
+			status:      403,
+			err:         &Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			description: "user listing by a normal user",
+		},
+		{
+			method:      "GET",
+			endpoint:    "cloud/users/" + einstein.Id.OpaqueId,
+			user:        einstein,
+			description: "own user details by id",
+		},
+		{
+			method:      "GET",
+			endpoint:    "cloud/users/" + einstein.Id.OpaqueId + "/groups",
+			user:        einstein,
+			description: "own groups",
+		},
+		{
+			method:      "GET",
+			endpoint:    "cloud/users/932b4540-8d16-481e-8ef4-588e4b6b151c",
+			user:        einstein,
+			status:      403,
+			err:         &Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			description: "details of another user",
+		},
+		{
+			method:      "DELETE",
+			endpoint:    "cloud/users/932b4540-8d16-481e-8ef4-588e4b6b151c",
+			user:        einstein,
+			status:      403,
+			err:         &Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			description: "deleting another user",
+		},
+		{
+			method:      "GET",
+			endpoint:    "cloud/groups",
+			user:        einstein,
+			status:      403,
+			err:         &Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			description: "group listing by a normal user",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				endpoint := fmt.Sprintf("/%v/%v%v", ocsVersion, data.endpoint, getFormatString(format))
+
+				var res *httptest.ResponseRecorder
+				var err error
+				if data.user == nil {
+					res, err = sendRequest(data.method, endpoint, "", "")
+				} else {
+					res, err = sendRequestAs(data.method, endpoint, "", data.user)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				if format == "json" {
+					if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+						t.Fatal(err)
+					}
+				} else {
+					if err := xml.Unmarshal(res.Body.Bytes(), &response.Ocs); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				if data.err == nil {
+					assertStatusCode(t, 200, res, ocsVersion)
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				} else {
+					assertStatusCode(t, data.status, res, ocsVersion)
+					assertResponseMeta(t, *data.err, response.Ocs.Meta)
+				}
+			}
+		}
+	}
+}
+
+func TestCustomAdminRole(t *testing.T) {
+	const (
+		managersRoleID = "c0a7a0c6-3cf1-4ef8-8e3b-6a3c4f5a9e1d"
+		guestsRoleID   = "e2b5f9a4-7d3c-4b1e-9f0a-2c6d8e4b1a37"
+	)
+	mockedRoles[managersRoleID] = adminRole(managersRoleID)
+	mockedRoles[guestsRoleID] = &settings.Bundle{Id: guestsRoleID, Type: settings.Bundle_TYPE_ROLE}
+	defer func() {
+		delete(mockedRoles, managersRoleID)
+		delete(mockedRoles, guestsRoleID)
+		delete(mockedRoleAssignment, einstein.Id.OpaqueId)
+	}()
+
+	testData := []struct {
+		role        string
+		status      int
+		description string
+	}{
+		{managersRoleID, 200, "a custom role with the admin permission grants admin access"},
+		{guestsRoleID, 403, "a role without the admin permission only grants user access"},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				mockedRoleAssignment[einstein.Id.OpaqueId] = data.role
+
+				res, err := sendRequestAs("GET", fmt.Sprintf("/%v/cloud/users%v", ocsVersion, getFormatString(format)), "", einstein)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, data.status, res, ocsVersion)
+				assert.Equal(t, data.status == 200, response.Ocs.Meta.Success(ocsVersion), data.description)
+			}
+		}
+	}
+}
+
+type SubadminsResponse struct {
+	Ocs struct {
+		Meta Meta     `json:"meta" xml:"meta"`
+		Data []string `json:"data" xml:"data>element"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+func TestSubadmins(t *testing.T) {
+	const (
+		marieID           = "f7fbf8c8-139b-4376-b307-cf0a8c2d0d9c"
+		sailingLovers     = "6040aa17-9c64-4fef-9bd0-77234d71bad0"
+		einsteinSubadmins = "/cloud/users/4c510ada-c86b-4815-8820-42cdf82c3d51/subadmins"
+	)
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			storeService = newFakeStore()
+			formatpart := getFormatString(format)
+
+			// a normal user cannot manage subadmins
+			res, err := sendRequestAs("POST", fmt.Sprintf("/%v%v%v", ocsVersion, einsteinSubadmins, formatpart), "groupid="+physicsLoversID, einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 403, res, ocsVersion)
+
+			// the group has to exist
+			res, err = sendRequest("POST", fmt.Sprintf("/%v%v%v", ocsVersion, einsteinSubadmins, formatpart), "groupid=not-a-group", "admin:admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var failed EmptyResponse
+			unmarshalResponse(t, format, res, &failed, &failed.Ocs)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 102, Message: "Group:not-a-group does not exist"}, failed.Ocs.Meta)
+
+			res, err = sendRequest("POST", fmt.Sprintf("/%v%v%v", ocsVersion, einsteinSubadmins, formatpart), "groupid="+physicsLoversID, "admin:admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+
+			res, err = sendRequest("GET", fmt.Sprintf("/%v%v%v", ocsVersion, einsteinSubadmins, formatpart), "", "admin:admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var groups SubadminsResponse
+			unmarshalResponse(t, format, res, &groups, &groups.Ocs)
+			assert.Equal(t, []string{physicsLoversID}, groups.Ocs.Data)
+
+			res, err = sendRequest("GET", fmt.Sprintf("/%v/cloud/groups/%v/subadmins%v", ocsVersion, physicsLoversID, formatpart), "", "admin:admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var subadmins SubadminsResponse
+			unmarshalResponse(t, format, res, &subadmins, &subadmins.Ocs)
+			assert.Equal(t, []string{einstein.Id.OpaqueId}, subadmins.Ocs.Data)
+
+			// the subadmin only sees the members of the administered group
+			res, err = sendRequestAs("GET", fmt.Sprintf("/%v/cloud/users%v", ocsVersion, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var users GetUsersResponse
+			unmarshalResponse(t, format, res, &users, &users.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.ElementsMatch(t, []string{einstein.Id.OpaqueId, richardID, marieID}, users.Ocs.Data.Users)
+
+			res, err = sendRequestAs("GET", fmt.Sprintf("/%v/cloud/users/%v%v", ocsVersion, richardID, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+
+			// users outside of the administered groups and admins are off limits
+			res, err = sendRequestAs("DELETE", fmt.Sprintf("/%v/cloud/users/%v%v", ocsVersion, adminID, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 403, res, ocsVersion)
+
+			// memberships can only be changed for administered groups
+			res, err = sendRequestAs("DELETE", fmt.Sprintf("/%v/cloud/users/%v/groups?format=%v&groupid=%v", ocsVersion, richardID, format, sailingLovers), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 403, res, ocsVersion)
+
+			// subadmins need to put new users into an administered group
+			res, err = sendRequestAs("POST", fmt.Sprintf("/%v/cloud/users%v", ocsVersion, formatpart), "userid=rutherford&username=rutherford&email=rutherford@example.com&password=secret", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var noGroup EmptyResponse
+			unmarshalResponse(t, format, res, &noGroup, &noGroup.Ocs)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 106, Message: "no group specified (required for subadmins)"}, noGroup.Ocs.Meta)
+
+			res, err = sendRequest("DELETE", fmt.Sprintf("/%v%v?format=%v&groupid=%v", ocsVersion, einsteinSubadmins, format, physicsLoversID), "", "admin:admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+
+			res, err = sendRequestAs("GET", fmt.Sprintf("/%v/cloud/users%v", ocsVersion, formatpart), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 403, res, ocsVersion)
+		}
+	}
+	cleanUp(t)
+}
+
+func TestGetUserQuota(t *testing.T) {
+	testData := []struct {
+		unavailable bool
+		expected    Quota
+		description string
+	}{
+		{
+			expected:    Quota{Free: 8200000000, Used: 1800000000, Total: 10000000000, Relative: 18, Definition: "default"},
+			description: "quota of the home storage",
+		},
+		{
+			unavailable: true,
+			expected:    Quota{Definition: "default"},
+			description: "storage unreachable",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				gatewayClient = newFakeGateway()
+				gatewayClient.quotaUnavailable = data.unavailable
+
+				res, err := sendRequest(
+					"GET",
+					fmt.Sprintf("/%v/cloud/users/%v%v", ocsVersion, einstein.Id.OpaqueId, getFormatString(format)),
+					"",
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response SingleUserResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				assert.Equal(t, data.expected, response.Ocs.Data.Quota, data.description)
+			}
+		}
+	}
+}
+
+func TestEditUserQuota(t *testing.T) {
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: adminID}}
+	testData := []struct {
+		user          *userpb.User
+		value         string
+		groupDefaults map[string]string
+		meta          Meta
+		status        int
+		expected      Quota
+		metadata      string
+		description   string
+	}{
+		{
+			user:        admin,
+			value:       "5 GB",
+			status:      200,
+			expected:    Quota{Free: 3568709120, Used: 1800000000, Total: 5368709120, Relative: 33.53, Definition: "5 GB"},
+			metadata:    "5368709120",
+			description: "quota with unit",
+		},
+		{
+			user:        admin,
+			value:       "4294967296",
+			status:      200,
+			expected:    Quota{Free: 2494967296, Used: 1800000000, Total: 4294967296, Relative: 41.91, Definition: "4294967296"},
+			metadata:    "4294967296",
+			description: "quota in bytes",
+		},
+		{
+			user:        admin,
+			value:       "none",
+			status:      200,
+			expected:    Quota{Free: 8200000000, Used: 1800000000, Total: 10000000000, Relative: 18, Definition: "none"},
+			metadata:    "none",
+			description: "unlimited quota",
+		},
+		{
+			user:          admin,
+			value:         "default",
+			groupDefaults: map[string]string{physicsLoversID: "1 GB"},
+			status:        200,
+			expected:      Quota{Used: 1800000000, Total: 1073741824, Relative: 167.64, Definition: "default"},
+			metadata:      "1073741824",
+			description:   "default quota of a group",
+		},
+		{
+			user:        admin,
+			value:       "lots",
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "Invalid quota value lots"},
+			status:      400,
+			expected:    Quota{Free: 8200000000, Used: 1800000000, Total: 10000000000, Relative: 18, Definition: "default"},
+			description: "invalid quota",
+		},
+		{
+			user:        admin,
+			value:       "5000000000 TB",
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "Invalid quota value 5000000000 TB"},
+			status:      400,
+			expected:    Quota{Free: 8200000000, Used: 1800000000, Total: 10000000000, Relative: 18, Definition: "default"},
+			description: "quota larger than an int64",
+		},
+		{
+			user:        einstein,
+			value:       "none",
+			meta:        Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			status:      403,
+			expected:    Quota{Free: 8200000000, Used: 1800000000, Total: 10000000000, Relative: 18, Definition: "default"},
+			description: "users cannot change their own quota",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				gatewayClient = newFakeGateway()
+				storeService = newFakeStore()
+				c := getConfig()
+				c.Quota.Groups = data.groupDefaults
+				service := getServiceWithConfig(c)
+
+				params := url.Values{"key": {"quota"}, "value": {data.value}}
+				res, err := sendRequestTo(
+					service,
+					"PUT",
+					fmt.Sprintf("/%v/cloud/users/%v%v", ocsVersion, einstein.Id.OpaqueId, getFormatString(format)),
+					params.Encode(),
+					data.user,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, data.status, res, ocsVersion)
+				if data.status == 200 {
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				} else {
+					assertResponseMeta(t, data.meta, response.Ocs.Meta)
+				}
+				assert.Equal(t, data.metadata, gatewayClient.metadata["quota"], data.description)
+
+				res, err = sendRequestTo(
+					service,
+					"GET",
+					fmt.Sprintf("/%v/cloud/users/%v%v", ocsVersion, einstein.Id.OpaqueId, getFormatString(format)),
+					"",
+					admin,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var user SingleUserResponse
+				unmarshalResponse(t, format, res, &user, &user.Ocs)
+
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.Equal(t, data.expected, user.Ocs.Data.Quota, data.description)
+			}
+		}
+	}
+	storeService = newFakeStore()
+}
+
+func TestEditUserQuotaFailures(t *testing.T) {
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: adminID}}
+
+	testData := []struct {
+		metadataUnavailable bool
+		writesUnavailable   bool
+		description         string
+	}{
+		{metadataUnavailable: true, description: "the storage does not accept the limit"},
+		{writesUnavailable: true, description: "the quota definition cannot be saved"},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				gatewayClient = newFakeGateway()
+				storeService = newFakeStore()
+				endpoint := fmt.Sprintf("/%v/cloud/users/%v%v", ocsVersion, einstein.Id.OpaqueId, getFormatString(format))
+
+				res, err := sendRequestAs("PUT", endpoint, url.Values{"key": {"quota"}, "value": {"5 GB"}}.Encode(), admin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertStatusCode(t, 200, res, ocsVersion)
+
+				gatewayClient.metadataUnavailable = data.metadataUnavailable
+				storeService.writesUnavailable = data.writesUnavailable
+				res, err = sendRequestAs("PUT", endpoint, url.Values{"key": {"quota"}, "value": {"1 GB"}}.Encode(), admin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+				assertStatusCode(t, 500, res, ocsVersion)
+				assertResponseMeta(t, Meta{Status: "error", StatusCode: 996, Message: "could not set quota"}, response.Ocs.Meta)
+
+				// neither the limit of the storage nor the stored definition changed
+				gatewayClient.metadataUnavailable = false
+				storeService.writesUnavailable = false
+				assert.Equal(t, "5368709120", gatewayClient.metadata["quota"], data.description)
+
+				res, err = sendRequestAs("GET", endpoint, "", admin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var user SingleUserResponse
+				unmarshalResponse(t, format, res, &user, &user.Ocs)
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.Equal(t, "5 GB", user.Ocs.Data.Quota.Definition, data.description)
+				assert.Equal(t, int64(5368709120), user.Ocs.Data.Quota.Total, data.description)
+			}
+		}
+	}
+	storeService = newFakeStore()
+}
+
+func TestEnableDisableUser(t *testing.T) {
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: adminID}}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			userEndpoint := fmt.Sprintf("/%v/cloud/user%v", ocsVersion, getFormatString(format))
+
+			res, err := sendRequestAs("PUT", fmt.Sprintf("/%v/cloud/users/%v/disable%v", ocsVersion, einstein.Id.OpaqueId, getFormatString(format)), "", admin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var response EmptyResponse
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "disabling the user was expected to be successful")
+
+			res, err = sendRequestAs("GET", userEndpoint, "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response = EmptyResponse{}
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+			assertStatusCode(t, 401, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 997, Message: "Unauthorised"}, response.Ocs.Meta)
+
+			res, err = sendRequestAs("GET", fmt.Sprintf("/%v/cloud/users/%v%v", ocsVersion, einstein.Id.OpaqueId, getFormatString(format)), "", admin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var user SingleUserResponse
+			unmarshalResponse(t, format, res, &user, &user.Ocs)
+			assert.Equal(t, "false", user.Ocs.Data.Enabled)
+
+			res, err = sendRequestAs("PUT", fmt.Sprintf("/%v/cloud/users/%v/enable%v", ocsVersion, einstein.Id.OpaqueId, getFormatString(format)), "", admin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response = EmptyResponse{}
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "enabling the user was expected to be successful")
+
+			res, err = sendRequestAs("GET", userEndpoint, "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 200, res.Code, "requests of enabled users must pass")
+
+			res, err = sendRequestAs("PUT", fmt.Sprintf("/%v/cloud/users/%v/disable%v", ocsVersion, adminID, getFormatString(format)), "", admin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response = EmptyResponse{}
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+			assertStatusCode(t, 400, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 101, Message: "cannot disable yourself"}, response.Ocs.Meta)
+
+			res, err = sendRequestAs("PUT", fmt.Sprintf("/%v/cloud/users/%v/disable%v", ocsVersion, richardID, getFormatString(format)), "", einstein)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response = EmptyResponse{}
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+			assertStatusCode(t, 403, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 403, Message: "Forbidden"}, response.Ocs.Meta)
+		}
+	}
+}
+
+func TestAddGroup(t *testing.T) {
+	testData := []struct {
+		params      url.Values
+		status      int
+		meta        Meta
+		created     bool
+		description string
+	}{
+		{
+			params:      url.Values{"groupid": {"string-theorists"}, "displayname": {"String Theorists"}, "gidnumber": {"31000"}},
+			status:      200,
+			created:     true,
+			description: "group with display name and gid number",
+		},
+		{
+			params:      url.Values{"groupid": {"physics-lovers"}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 102, Message: "group exists"},
+			description: "group name already taken",
+		},
+		{
+			params:      url.Values{"groupid": {physicsLoversID}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 102, Message: "group exists"},
+			description: "group id already taken",
+		},
+		{
+			params:      url.Values{"displayname": {"Nameless"}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "Invalid group name"},
+			description: "missing group id",
+		},
+		{
+			params:      url.Values{"groupid": {"a/b"}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "Invalid group name"},
+			description: "invalid group id",
+		},
+		{
+			params:      url.Values{"groupid": {"string-theorists"}, "gidnumber": {"many"}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "Invalid gidnumber many"},
+			description: "invalid gid number",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				res, err := sendRequest(
+					"POST",
+					fmt.Sprintf("/%v/cloud/groups%v", ocsVersion, getFormatString(format)),
+					data.params.Encode(),
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, data.status, res, ocsVersion)
+				if data.status == 200 {
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				} else {
+					assertResponseMeta(t, data.meta, response.Ocs.Meta)
+				}
+				if !data.created {
+					continue
+				}
+
+				groupid := data.params.Get("groupid")
+				res, err = sendRequest(
+					"GET",
+					fmt.Sprintf("/%v/cloud/groups?format=%v&search=%v", ocsVersion, format, groupid),
+					"",
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var groups GetUsersGroupsResponse
+				unmarshalResponse(t, format, res, &groups, &groups.Ocs)
+				assert.Equal(t, []string{groupid}, groups.Ocs.Data.Groups, data.description)
+
+				res, err = sendRequest(
+					"DELETE",
+					fmt.Sprintf("/%v/cloud/groups/%v%v", ocsVersion, groupid, getFormatString(format)),
+					"",
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertStatusCode(t, 200, res, ocsVersion)
+			}
+		}
+	}
+}
+
+func TestEditGroupInvalidInput(t *testing.T) {
+	testData := []struct {
+		groupid     string
+		params      url.Values
+		status      int
+		meta        Meta
+		description string
+	}{
+		{
+			groupid:     "not-existing-group",
+			params:      url.Values{"key": {"displayname"}, "value": {"Ghosts"}},
+			status:      404,
+			meta:        Meta{Status: "error", StatusCode: 998, Message: "The requested group could not be found"},
+			description: "group does not exist",
+		},
+		{
+			groupid:     physicsLoversID,
+			params:      url.Values{"key": {"members"}, "value": {"einstein"}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "unknown key 'members'"},
+			description: "unknown key",
+		},
+		{
+			groupid:     physicsLoversID,
+			params:      url.Values{"key": {"name"}, "value": {"radium-lovers"}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 102, Message: "group exists"},
+			description: "rename to a taken name",
+		},
+		{
+			groupid:     physicsLoversID,
+			params:      url.Values{"key": {"name"}, "value": {""}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "Invalid group name"},
+			description: "rename to an empty name",
+		},
+		{
+			groupid:     physicsLoversID,
+			params:      url.Values{"key": {"displayname"}, "value": {""}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "Invalid display name"},
+			description: "empty display name",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				res, err := sendRequest(
+					"PUT",
+					fmt.Sprintf("/%v/cloud/groups/%v%v", ocsVersion, data.groupid, getFormatString(format)),
+					data.params.Encode(),
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, data.status, res, ocsVersion)
+				assertResponseMeta(t, data.meta, response.Ocs.Meta)
+			}
+		}
+	}
+}
+
+func TestRenameGroup(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			res, err := sendRequest(
+				"POST",
+				fmt.Sprintf("/%v/cloud/groups%v", ocsVersion, getFormatString(format)),
+				url.Values{"groupid": {"string-theorists"}}.Encode(),
+				"admin:admin",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+
+			renames := []struct {
+				groupid string
+				name    string
+			}{
+				{groupid: "string-theorists", name: "string-theory"},
+				{groupid: "string-theory", name: "m-theory"},
+			}
+			for _, rename := range renames {
+				res, err = sendRequest(
+					"PUT",
+					fmt.Sprintf("/%v/cloud/groups/%v%v", ocsVersion, rename.groupid, getFormatString(format)),
+					url.Values{"key": {"name"}, "value": {rename.name}}.Encode(),
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.True(t, response.Ocs.Meta.Success(ocsVersion), "renaming %v to %v was expected to be successful", rename.groupid, rename.name)
+			}
+
+			res, err = sendRequest(
+				"GET",
+				fmt.Sprintf("/%v/cloud/groups/m-theory%v", ocsVersion, getFormatString(format)),
+				"",
+				"admin:admin",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+
+			res, err = sendRequest(
+				"GET",
+				fmt.Sprintf("/%v/cloud/groups/string-theory%v", ocsVersion, getFormatString(format)),
+				"",
+				"admin:admin",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 404, res, ocsVersion)
+
+			res, err = sendRequest(
+				"DELETE",
+				fmt.Sprintf("/%v/cloud/groups/m-theory%v", ocsVersion, getFormatString(format)),
+				"",
+				"admin:admin",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertStatusCode(t, 200, res, ocsVersion)
+		}
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	testData := []struct {
+		search      string
+		expected    []string
+		description string
+	}{
+		{search: "EINST", expected: []string{einstein.Id.OpaqueId}, description: "username prefix ignoring case"},
+		{search: "albert", expected: []string{einstein.Id.OpaqueId}, description: "display name prefix"},
+		{search: "4c510ada", expected: []string{einstein.Id.OpaqueId}, description: "id prefix"},
+		{search: "ri", expected: []string{richardID}, description: "only the beginning is matched"},
+		{search: "nobody'", expected: []string{}, description: "no match"},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				res, err := sendRequest(
+					"GET",
+					fmt.Sprintf("/%v/cloud/users?format=%v&%v", ocsVersion, format, url.Values{"search": {data.search}}.Encode()),
+					"",
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response GetUsersResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				expected := append([]string{}, data.expected...)
+				sort.Strings(expected)
+				if len(expected) == 0 {
+					assert.Empty(t, response.Ocs.Data.Users, data.description)
+				} else {
+					assert.Equal(t, expected, response.Ocs.Data.Users, data.description)
+				}
+			}
+		}
+	}
+}
+
+func TestSearchUsersDetails(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			res, err := sendRequest(
+				"GET",
+				fmt.Sprintf("/%v/cloud/users?format=%v&search=einstein&details=true", ocsVersion, format),
+				"",
+				"admin:admin",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var response struct {
+				Ocs struct {
+					Meta Meta `json:"meta" xml:"meta"`
+					Data struct {
+						Users []User `json:"users" xml:"users>element"`
+					} `json:"data" xml:"data"`
+				} `json:"ocs" xml:"ocs"`
+			}
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			if assert.Len(t, response.Ocs.Data.Users, 1) {
+				u := response.Ocs.Data.Users[0]
+				assert.Equal(t, einstein.Id.OpaqueId, u.ID)
+				assert.Equal(t, "einstein", u.Username)
+				assert.Equal(t, "Albert Einstein", u.Displayname)
+				assert.Equal(t, "true", u.Enabled)
+			}
+		}
+	}
+}
+
+type GroupDetailsResponse struct {
+	Ocs struct {
+		Meta Meta `json:"meta" xml:"meta"`
+		Data struct {
+			Groups []struct {
+				ID          string `json:"id" xml:"id"`
+				DisplayName string `json:"displayname" xml:"displayname"`
+				GIDNumber   int64  `json:"gidnumber" xml:"gidnumber"`
+				MemberCount int    `json:"membercount" xml:"membercount"`
+			} `json:"groups" xml:"groups>element"`
+		} `json:"data" xml:"data"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+func TestSearchGroups(t *testing.T) {
+	testData := []struct {
+		search      string
+		expected    []string
+		description string
+	}{
+		{search: "PHYSICS", expected: []string{physicsLoversID}, description: "group name ignoring case"},
+		{search: "262982c1", expected: []string{physicsLoversID}, description: "id prefix"},
+		{search: "nobody'", expected: []string{}, description: "no match"},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				res, err := sendRequest(
+					"GET",
+					fmt.Sprintf("/%v/cloud/groups?format=%v&%v", ocsVersion, format, url.Values{"search": {data.search}}.Encode()),
+					"",
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response GetUsersGroupsResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				if len(data.expected) == 0 {
+					assert.Empty(t, response.Ocs.Data.Groups, data.description)
+				} else {
+					assert.Equal(t, data.expected, response.Ocs.Data.Groups, data.description)
+				}
+			}
+		}
+	}
+}
+
+func TestGroupDetails(t *testing.T) {
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			res, err := sendRequest(
+				"GET",
+				fmt.Sprintf("/%v/cloud/groups?format=%v&search=physics-lovers&details=true", ocsVersion, format),
+				"",
+				"admin:admin",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var response GroupDetailsResponse
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			if assert.Len(t, response.Ocs.Data.Groups, 1) {
+				g := response.Ocs.Data.Groups[0]
+				assert.Equal(t, physicsLoversID, g.ID)
+				assert.NotEmpty(t, g.DisplayName)
+				assert.NotZero(t, g.MemberCount)
+			}
+
+			res, err = sendRequest(
+				"GET",
+				fmt.Sprintf("/%v/cloud/users/%v/groups?format=%v&details=true", ocsVersion, einstein.Id.OpaqueId, format),
+				"",
+				"admin:admin",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response = GroupDetailsResponse{}
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+			assertStatusCode(t, 200, res, ocsVersion)
+			assert.True(t, response.Ocs.Meta.Success(ocsVersion), "The response was expected to be successful but was not")
+			ids := []string{}
+			for _, g := range response.Ocs.Data.Groups {
+				ids = append(ids, g.ID)
+				assert.NotZero(t, g.MemberCount, "einstein is a member of %v", g.ID)
+			}
+			assert.Contains(t, ids, physicsLoversID)
+		}
+	}
+}
+
+// sendEncodedRequest sends a request with the given content type as the admin user
+func sendEncodedRequest(method, endpoint, contentType, body string) (*httptest.ResponseRecorder, error) {
+	t, err := mintToken(&userpb.User{Id: &userpb.UserId{OpaqueId: adminID}, Username: "moss"})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-access-token", t)
+
+	rr := httptest.NewRecorder()
+	getService().ServeHTTP(rr, req)
+	return rr, nil
+}
+
+func TestRequestBodyEncodings(t *testing.T) {
+	userGroups := func(t *testing.T, userid string) []string {
+		res, err := sendRequest("GET", fmt.Sprintf("/v1.php/cloud/users/%s/groups?format=json", userid), "", "admin:admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response GetUsersGroupsResponse
+		if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Ocs.Data.Groups
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		// create a user with a json body
+		body, _ := json.Marshal(map[string]interface{}{
+			"userid":      "bohr",
+			"username":    "bohr",
+			"email":       "bohr@example.com",
+			"password":    "complementarity",
+			"displayname": "Niels Bohr",
+			"groups":      []string{physicsLoversID},
+		})
+		res, err := sendEncodedRequest("POST", fmt.Sprintf("/%v/cloud/users?format=json", ocsVersion), "application/json", string(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response EmptyResponse
+		if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assertStatusCode(t, 200, res, ocsVersion)
+		assert.True(t, response.Ocs.Meta.Success(ocsVersion), "creating a user from a json body was expected to be successful")
+		assert.Contains(t, userGroups(t, "bohr"), physicsLoversID)
+
+		// edit the user with a multipart body
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		_ = mw.WriteField("key", "displayname")
+		_ = mw.WriteField("value", "Niels Henrik David Bohr")
+		mw.Close()
+		res, err = sendEncodedRequest("PUT", fmt.Sprintf("/%v/cloud/users/bohr?format=json", ocsVersion), mw.FormDataContentType(), buf.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		response = EmptyResponse{}
+		if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assertStatusCode(t, 200, res, ocsVersion)
+		assert.True(t, response.Ocs.Meta.Success(ocsVersion), "editing a user with a multipart body was expected to be successful")
+
+		res, err = sendRequest("GET", fmt.Sprintf("/%v/cloud/users/bohr?format=json", ocsVersion), "", "admin:admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var user SingleUserResponse
+		if err := json.Unmarshal(res.Body.Bytes(), &user); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "Niels Henrik David Bohr", user.Ocs.Data.Displayname)
+
+		// remove the group membership with a json body in a DELETE request
+		res, err = sendEncodedRequest("DELETE", fmt.Sprintf("/%v/cloud/users/bohr/groups?format=json", ocsVersion), "application/json", fmt.Sprintf(`{"groupid": "%s"}`, physicsLoversID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response = EmptyResponse{}
+		if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assertStatusCode(t, 200, res, ocsVersion)
+		assert.True(t, response.Ocs.Meta.Success(ocsVersion), "removing a group membership with a json body was expected to be successful")
+		assert.NotContains(t, userGroups(t, "bohr"), physicsLoversID)
+
+		// malformed bodies are rejected
+		res, err = sendEncodedRequest("POST", fmt.Sprintf("/%v/cloud/users/bohr/groups?format=json", ocsVersion), "application/json", `{"groupid": `)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response = EmptyResponse{}
+		if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assertStatusCode(t, 400, res, ocsVersion)
+		assert.Equal(t, 400, response.Ocs.Meta.StatusCode)
+
+		// bodies larger than 10 MB are rejected
+		large := "key=displayname&value=" + strings.Repeat("a", 10<<20)
+		res, err = sendEncodedRequest("PUT", fmt.Sprintf("/%v/cloud/users/bohr?format=json", ocsVersion), "application/x-www-form-urlencoded", large)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response = EmptyResponse{}
+		if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assertStatusCode(t, 400, res, ocsVersion)
+		assert.Equal(t, 400, response.Ocs.Meta.StatusCode)
+
+		cleanUp(t)
+	}
+}
+
+type BatchResponse struct {
+	Ocs struct {
+		Meta Meta `json:"meta" xml:"meta"`
+		Data struct {
+			Users []struct {
+				ID         string `json:"id" xml:"id"`
+				Status     string `json:"status" xml:"status"`
+				StatusCode int    `json:"statuscode" xml:"statuscode"`
+				Message    string `json:"message" xml:"message"`
+			} `json:"users" xml:"users>element"`
+		} `json:"data" xml:"data"`
+	} `json:"ocs" xml:"ocs"`
+}
+
+func TestAddUsersBatch(t *testing.T) {
+	newUser := func(id, uidnumber string) map[string]interface{} {
+		return map[string]interface{}{
+			"userid":      id,
+			"username":    id,
+			"email":       id + "@example.com",
+			"password":    id + "-secret",
+			"displayname": strings.Title(id),
+			"uidnumber":   uidnumber,
+		}
+	}
+	userExists := func(t *testing.T, id string) bool {
+		res, err := sendRequest("GET", fmt.Sprintf("/v1.php/cloud/users/%s?format=json", id), "", "admin:admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response SingleUserResponse
+		if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Ocs.Meta.Success("v1.php")
+	}
+
+	testData := []struct {
+		users       []map[string]interface{}
+		atomic      bool
+		codes       []int
+		exists      []bool
+		description string
+	}{
+		{
+			users:       []map[string]interface{}{newUser("heisenberg", ""), newUser("schroedinger", "33000"), newUser("pauli", "many")},
+			codes:       []int{100, 100, 400},
+			exists:      []bool{true, true, false},
+			description: "partial failure",
+		},
+		{
+			users:       []map[string]interface{}{newUser("dirac", ""), newUser("born", "many")},
+			atomic:      true,
+			codes:       []int{101, 400},
+			exists:      []bool{false, false},
+			description: "created users are rolled back in atomic mode",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				body, _ := json.Marshal(map[string]interface{}{"users": data.users, "atomic": data.atomic})
+				res, err := sendEncodedRequest(
+					"POST",
+					fmt.Sprintf("/%v/cloud/users/batch%v", ocsVersion, getFormatString(format)),
+					"application/json",
+					string(body),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response BatchResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, 200, res, ocsVersion)
+				assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				if assert.Len(t, response.Ocs.Data.Users, len(data.users), data.description) {
+					for i, u := range response.Ocs.Data.Users {
+						assert.Equal(t, data.users[i]["userid"], u.ID, data.description)
+						assert.Equal(t, data.codes[i], u.StatusCode, "%v: %v", data.description, u.Message)
+					}
+				}
+				for i, u := range data.users {
+					assert.Equal(t, data.exists[i], userExists(t, u["userid"].(string)), "%v: %v", data.description, u["userid"])
+				}
+				cleanUp(t)
+			}
+		}
+	}
+}
+
+func TestCreateUserWithGroups(t *testing.T) {
+	type createdUserResponse struct {
+		Ocs struct {
+			Meta Meta `json:"meta" xml:"meta"`
+			Data struct {
+				ID     string   `json:"id" xml:"id"`
+				Groups []string `json:"groups" xml:"groups>element"`
+			} `json:"data" xml:"data"`
+		} `json:"ocs" xml:"ocs"`
+	}
+
+	testData := []struct {
+		groups      []string
+		status      int
+		meta        Meta
+		created     bool
+		description string
+	}{
+		{
+			groups:      []string{physicsLoversID},
+			status:      200,
+			created:     true,
+			description: "user is added to the groups",
+		},
+		{
+			groups:      []string{physicsLoversID, "not-existing-group"},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 104, Message: "group not-existing-group does not exist"},
+			description: "unknown groups are rejected before the user is created",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				params := url.Values{
+					"userid":      {"planck"},
+					"username":    {"planck"},
+					"email":       {"planck@example.com"},
+					"password":    {"quantum"},
+					"displayname": {"Max Planck"},
+					"groups[]":    data.groups,
+				}
+				res, err := sendRequest(
+					"POST",
+					fmt.Sprintf("/%v/cloud/users%v", ocsVersion, getFormatString(format)),
+					params.Encode(),
+					"admin:admin",
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response createdUserResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, data.status, res, ocsVersion)
+				if data.created {
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+					assert.Equal(t, "planck", response.Ocs.Data.ID, data.description)
+					for _, g := range data.groups {
+						assert.Contains(t, response.Ocs.Data.Groups, g, data.description)
+					}
+				} else {
+					assertResponseMeta(t, data.meta, response.Ocs.Meta)
+				}
+
+				res, err = sendRequest("GET", fmt.Sprintf("/%v/cloud/users/planck?format=json", ocsVersion), "", "admin:admin")
+				if err != nil {
+					t.Fatal(err)
+				}
+				var user SingleUserResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &user); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, data.created, user.Ocs.Meta.Success(ocsVersion), data.description)
+				cleanUp(t)
+			}
+		}
+	}
+}
+
+func TestEditCurrentUser(t *testing.T) {
+	testData := []struct {
+		params      url.Values
+		status      int
+		meta        Meta
+		description string
+	}{
+		{
+			params:      url.Values{"key": {"displayname"}, "value": {"Albert Einstein"}},
+			status:      200,
+			description: "change the display name",
+		},
+		{
+			params:      url.Values{"key": {"email"}, "value": {"einstein@example.org"}},
+			status:      200,
+			description: "change the email",
+		},
+		{
+			params:      url.Values{"key": {"password"}, "value": {"relativity"}, "currentpassword": {"relativity"}},
+			status:      200,
+			description: "change the password with the current password",
+		},
+		{
+			params:      url.Values{"key": {"password"}, "value": {"newtonian"}, "currentpassword": {"gravity"}},
+			status:      403,
+			meta:        Meta{Status: "error", StatusCode: 403, Message: "The current password is not correct"},
+			description: "wrong current password",
+		},
+		{
+			params:      url.Values{"key": {"password"}, "value": {"newtonian"}},
+			status:      403,
+			meta:        Meta{Status: "error", StatusCode: 403, Message: "The current password is not correct"},
+			description: "missing current password",
+		},
+		{
+			params:      url.Values{"key": {"quota"}, "value": {"none"}},
+			status:      403,
+			meta:        Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			description: "quota is admin only",
+		},
+		{
+			params:      url.Values{"key": {"enabled"}, "value": {"true"}},
+			status:      403,
+			meta:        Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			description: "enabled is admin only",
+		},
+		{
+			params:      url.Values{"key": {"shoesize"}, "value": {"42"}},
+			status:      400,
+			meta:        Meta{Status: "error", StatusCode: 103, Message: "unknown key 'shoesize'"},
+			description: "unknown key",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				res, err := sendRequestAs(
+					"PUT",
+					fmt.Sprintf("/%v/cloud/user%v", ocsVersion, getFormatString(format)),
+					data.params.Encode(),
+					einstein,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, data.status, res, ocsVersion)
+				if data.status == 200 {
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				} else {
+					assertResponseMeta(t, data.meta, response.Ocs.Meta)
+				}
+			}
+		}
+	}
+}
+
+func TestEditOwnUserAdminFields(t *testing.T) {
+	testData := []struct {
+		params      url.Values
+		meta        Meta
+		description string
+	}{
+		{
+			params:      url.Values{"key": {"password"}, "value": {"newtonian"}},
+			meta:        Meta{Status: "error", StatusCode: 403, Message: "Use PUT /cloud/user with the current password to change your own password"},
+			description: "own password without the current password",
+		},
+		{
+			params:      url.Values{"key": {"username"}, "value": {"newton"}},
+			meta:        Meta{Status: "error", StatusCode: 403, Message: "Forbidden"},
+			description: "own username",
+		},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				res, err := sendRequestAs(
+					"PUT",
+					fmt.Sprintf("/%v/cloud/users/%v%v", ocsVersion, einstein.Id.OpaqueId, getFormatString(format)),
+					data.params.Encode(),
+					einstein,
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				assertStatusCode(t, 403, res, ocsVersion)
+				assertResponseMeta(t, data.meta, response.Ocs.Meta)
+			}
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	disallowed, err := ioutil.TempFile("", "disallowed-passwords")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(disallowed.Name())
+	if _, err := disallowed.WriteString("# common passwords\nPassword1\n"); err != nil {
+		t.Fatal(err)
+	}
+	disallowed.Close()
+
+	c := getConfig()
+	c.PasswordPolicy = config.PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        32,
+		MinUpperCase:     1,
+		MinDigits:        1,
+		DisallowedFile:   disallowed.Name(),
+		DisallowUsername: true,
+	}
+	service := getServiceWithConfig(c)
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: adminID}, Username: "moss"}
+
+	testData := []struct {
+		username    string
+		password    string
+		message     string
+		description string
+	}{
+		{"heisenberg", "short", "The password must be at least 8 characters long", "too short"},
+		{"heisenberg", strings.Repeat("Uncertain1", 4), "The password must be at most 32 characters long", "too long"},
+		{"heisenberg", "uncertainty1", "The password must contain at least 1 uppercase letter", "missing uppercase letter"},
+		{"heisenberg", "Uncertainty", "The password must contain at least 1 digit", "missing digit"},
+		{"heisenberg1", "Heisenberg1", "The password must not contain the username", "username as password"},
+		{"heisenberg", "MrHeisenberg1", "The password must not contain the username", "password containing the username"},
+		{"heisenberg", "Password1", "The password is too common, choose a different one", "disallowed password"},
+		{"heisenberg", "Uncertainty1", "", "valid password"},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			formatpart := getFormatString(format)
+
+			res, err := sendRequestTo(service, "GET", fmt.Sprintf("/%v/cloud/capabilities%v", ocsVersion, formatpart), "", admin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var capabilities GetCapabilitiesResponse
+			unmarshalResponse(t, format, res, &capabilities, &capabilities.Ocs)
+			assert.Equal(t, 8, capabilities.Ocs.Data.Capabilities.PasswordPolicy.MinCharacters)
+			assert.Equal(t, 1, capabilities.Ocs.Data.Capabilities.PasswordPolicy.MinDigits)
+
+			for _, data := range testData {
+				params := url.Values{
+					"userid":   {data.username},
+					"username": {data.username},
+					"email":    {data.username + "@example.org"},
+					"password": {data.password},
+				}
+				res, err := sendRequestTo(service, "POST", fmt.Sprintf("/%v/cloud/users%v", ocsVersion, formatpart), params.Encode(), admin)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var response EmptyResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+				if data.message == "" {
+					assertStatusCode(t, 200, res, ocsVersion)
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+				} else {
+					assertStatusCode(t, 403, res, ocsVersion)
+					assertResponseMeta(t, Meta{Status: "error", StatusCode: 403, Message: data.message}, response.Ocs.Meta)
+				}
+			}
+
+			// changing the password is checked against the same policy
+			params := url.Values{"key": {"password"}, "value": {"heisenberg"}}
+			res, err = sendRequestTo(service, "PUT", fmt.Sprintf("/%v/cloud/users/heisenberg%v", ocsVersion, formatpart), params.Encode(), admin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var response EmptyResponse
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+			assertStatusCode(t, 403, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 403, Message: "The password must be at least 8 characters long"}, response.Ocs.Meta)
+
+			params = url.Values{"key": {"password"}, "value": {"Heisenberg"}}
+			res, err = sendRequestTo(service, "PUT", fmt.Sprintf("/%v/cloud/users/heisenberg%v", ocsVersion, formatpart), params.Encode(), admin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unmarshalResponse(t, format, res, &response, &response.Ocs)
+			assertStatusCode(t, 403, res, ocsVersion)
+			assertResponseMeta(t, Meta{Status: "error", StatusCode: 403, Message: "The password must contain at least 1 digit"}, response.Ocs.Meta)
+
+			cleanUp(t)
+		}
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	const (
+		issuer   = "https://idp.example.com"
+		audience = "ocs"
+		keyID    = "ocs-test"
+	)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := ioutil.TempFile("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(jwks.Name())
+	err = json.NewEncoder(jwks).Encode(jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: keyID, Algorithm: "RS256", Use: "sig"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks.Close()
+
+	c := getConfig()
+	c.Authentication.OIDC = config.OIDC{
+		Issuer:   issuer,
+		Audience: audience,
+		JWKSFile: jwks.Name(),
+	}
+	service := getServiceWithConfig(c)
+
+	// the subject is the id of the user at the issuer, the account is found by the username
+	sign := func(k *rsa.PrivateKey, claims jwt.Claims, username string) string {
+		signer, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: k, KeyID: keyID}},
+			(&jose.SignerOptions{}).WithType("JWT"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		extra := map[string]interface{}{
+			"email":  "einstein@example.org",
+			"groups": []string{"physics-lovers"},
+		}
+		if username != "" {
+			extra["preferred_username"] = username
+		}
+		raw, err := jwt.Signed(signer).Claims(claims).Claims(extra).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	valid := func() jwt.Claims {
+		return jwt.Claims{
+			Issuer:   issuer,
+			Subject:  "idp-user-4711",
+			Audience: jwt.Audience{audience},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}
+
+	expired := valid()
+	expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongAudience := valid()
+	wrongAudience.Audience = jwt.Audience{"other"}
+	wrongIssuer := valid()
+	wrongIssuer.Issuer = "https://evil.example.com"
+	noSubject := valid()
+	noSubject.Subject = ""
+
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: adminID}}
+	res, err := sendRequestAs("PUT", fmt.Sprintf("/v1.php/cloud/users/%v/disable", richardID), "", admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, res.Code, "disabling the user was expected to be successful")
+	defer func() {
+		if _, err := sendRequestAs("PUT", fmt.Sprintf("/v1.php/cloud/users/%v/enable", richardID), "", admin); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	byMail := *c
+	byMail.Authentication.OIDC.UserClaim = "email"
+	byMail.Authentication.OIDC.AccountField = "mail"
+	byID := *c
+	byID.Authentication.OIDC.UserClaim = "sub"
+	byID.Authentication.OIDC.AccountField = "id"
+	accountIDToken := valid()
+	accountIDToken.Subject = einstein.Id.OpaqueId
+
+	testData := []struct {
+		service     http.Handler
+		token       string
+		success     bool
+		description string
+	}{
+		{service, sign(key, valid(), einstein.Username), true, "valid token"},
+		{getServiceWithConfig(&byMail), sign(key, valid(), ""), true, "account found by email"},
+		{getServiceWithConfig(&byID), sign(key, accountIDToken, ""), true, "account found by id"},
+		{getServiceWithConfig(&byID), sign(key, valid(), einstein.Username), false, "subject is not an account id"},
+		{service, sign(key, expired, einstein.Username), false, "expired token"},
+		{service, sign(key, wrongAudience, einstein.Username), false, "token for another audience"},
+		{service, sign(key, wrongIssuer, einstein.Username), false, "token of another issuer"},
+		{service, sign(key, noSubject, einstein.Username), false, "token without subject"},
+		{service, sign(key, valid(), ""), false, "token without user claim"},
+		{service, sign(otherKey, valid(), einstein.Username), false, "token signed with another key"},
+		{service, sign(key, valid(), "not-a-user"), false, "token of an unknown account"},
+		{service, sign(key, valid(), "richard"), false, "token of a disabled account"},
+		{service, "not-a-jwt", false, "malformed token"},
+	}
+
+	for _, ocsVersion := range ocsVersions {
+		for _, format := range formats {
+			for _, data := range testData {
+				req, err := http.NewRequest("GET", fmt.Sprintf("/%v/cloud/user%v", ocsVersion, getFormatString(format)), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+data.token)
+				res := httptest.NewRecorder()
+				data.service.ServeHTTP(res, req)
+
+				var response SingleUserResponse
+				unmarshalResponse(t, format, res, &response, &response.Ocs)
+
+				if data.success {
+					assertStatusCode(t, 200, res, ocsVersion)
+					assert.True(t, response.Ocs.Meta.Success(ocsVersion), "%v: the response was expected to be successful but was not", data.description)
+					assert.Equal(t, einstein.Id.OpaqueId, response.Ocs.Data.ID)
+				} else {
+					assertStatusCode(t, 401, res, ocsVersion)
+					assertResponseMeta(t, Meta{Status: "error", StatusCode: 997, Message: "Unauthorised"}, response.Ocs.Meta)
+					assert.Contains(t, res.Header().Get("WWW-Authenticate"), "Bearer", data.description)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package svc
+
+import (
+	"context"
+	"net/http"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/user"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	merrors "github.com/micro/go-micro/v2/errors"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+	settings "github.com/owncloud/ocis-settings/pkg/proto/v0"
+)
+
+type contextKey int
+
+const (
+	// subadminScopeKey holds the groups administered by a subadmin, it is only set for subadmins
+	subadminScopeKey contextKey = iota
+)
+
+// requireUser rejects requests without an authenticated user
+func (o Ocs) requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := currentUser(r.Context()); !ok {
+			render.Render(w, r, response.ErrRender(data.MetaUnauthorized.StatusCode, "missing user in context"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin only lets requests of users with an admin role pass
+func (o Ocs) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, admin, ok := o.authorize(w, r)
+		if !ok {
+			return
+		}
+		if !admin {
+			o.logger.Debug().Str("userid", u.Id.OpaqueId).Str("path", r.URL.Path).Msg("admin role required")
+			render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "Forbidden"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireSubadmin lets admins and subadmins pass. For subadmins the administered groups are stored in the
+// request context, handlers have to limit their results to those groups.
+func (o Ocs) requireSubadmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, admin, ok := o.authorize(w, r)
+		if !ok {
+			return
+		}
+		if admin {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		groups, ok := o.subadminGroups(w, r, u)
+		if !ok {
+			return
+		}
+		if len(groups) == 0 {
+			o.logger.Debug().Str("userid", u.Id.OpaqueId).Str("path", r.URL.Path).Msg("admin or subadmin role required")
+			render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "Forbidden"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), subadminScopeKey, groups)))
+	})
+}
+
+// requireUserManager lets requests pass if the current user is an admin or a subadmin of one of the groups
+// of the user in the userid url parameter. Subadmins cannot manage admins.
+// If allowSelf is set users may also manage their own record.
+func (o Ocs) requireUserManager(allowSelf bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, admin, ok := o.authorize(w, r)
+			if !ok {
+				return
+			}
+			userid := chi.URLParam(r, "userid")
+			if admin || (allowSelf && isSelf(u, userid)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			groups, ok := o.subadminGroups(w, r, u)
+			if !ok {
+				return
+			}
+			if len(groups) == 0 {
+				o.logger.Debug().Str("userid", u.Id.OpaqueId).Str("path", r.URL.Path).Msg("access to other users requires an admin or subadmin role")
+				render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "Forbidden"))
+				return
+			}
+
+			account, err := o.getAccountService().GetAccount(r.Context(), &accounts.GetAccountRequest{Id: userid})
+			if err != nil {
+				if merrors.FromError(err).Code == http.StatusNotFound {
+					render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested user could not be found"))
+					return
+				}
+				o.logger.Error().Err(err).Str("userid", userid).Msg("could not get user")
+				render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+				return
+			}
+			if !memberOfAny(account, groups) {
+				o.logger.Debug().Str("userid", u.Id.OpaqueId).Str("target", userid).Msg("user is not in a group administered by the subadmin")
+				render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "Forbidden"))
+				return
+			}
+
+			targetAdmin, err := o.isAdmin(r.Context(), &userpb.User{Id: &userpb.UserId{OpaqueId: account.Id}})
+			if err != nil {
+				o.logger.Error().Err(err).Str("userid", account.Id).Msg("could not get role assignments")
+				render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not check permissions"))
+				return
+			}
+			if targetAdmin {
+				o.logger.Debug().Str("userid", u.Id.OpaqueId).Str("target", userid).Msg("subadmins cannot manage admins")
+				render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "Forbidden"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireGroupManager lets requests pass if the current user is an admin or a subadmin of the group in the
+// groupid parameter
+func (o Ocs) requireGroupManager(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, admin, ok := o.authorize(w, r)
+		if !ok {
+			return
+		}
+		if admin {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		groups, ok := o.subadminGroups(w, r, u)
+		if !ok {
+			return
+		}
+		if groupid := groupIDParam(r); groupid == "" || !groups[groupid] {
+			o.logger.Debug().Str("userid", u.Id.OpaqueId).Str("groupid", groupid).Msg("admin or subadmin of the group required")
+			render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "Forbidden"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorize returns the current user and if it has an admin role.
+// If there is no user or the roles cannot be checked an ocs error is rendered and false is returned.
+func (o Ocs) authorize(w http.ResponseWriter, r *http.Request) (*userpb.User, bool, bool) {
+	u, ok := currentUser(r.Context())
+	if !ok {
+		render.Render(w, r, response.ErrRender(data.MetaUnauthorized.StatusCode, "missing user in context"))
+		return nil, false, false
+	}
+
+	admin, err := o.isAdmin(r.Context(), u)
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not get role assignments")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not check permissions"))
+		return nil, false, false
+	}
+	return u, admin, true
+}
+
+// subadminGroups returns the groups administered by the user as a set.
+// If they cannot be read an ocs error is rendered and false is returned.
+func (o Ocs) subadminGroups(w http.ResponseWriter, r *http.Request, u *userpb.User) (map[string]bool, bool) {
+	ids, err := o.listSubadminGroups(r.Context(), u.Id.OpaqueId)
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not get subadmin groups")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not check permissions"))
+		return nil, false
+	}
+
+	groups := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		groups[id] = true
+	}
+	return groups, true
+}
+
+// isAdmin checks if one of the roles assigned to the user grants the configured admin permission for all accounts
+func (o Ocs) isAdmin(ctx context.Context, u *userpb.User) (bool, error) {
+	res, err := o.getRoleService().ListRoleAssignments(ctx, &settings.ListRoleAssignmentsRequest{
+		AccountUuid: u.Id.OpaqueId,
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(res.Assignments) == 0 {
+		return false, nil
+	}
+
+	roleIDs := make([]string, 0, len(res.Assignments))
+	for _, assignment := range res.Assignments {
+		roleIDs = append(roleIDs, assignment.RoleId)
+	}
+	roles, err := o.getRoleService().ListRoles(ctx, &settings.ListBundlesRequest{
+		BundleIds: roleIDs,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range roles.Bundles {
+		for _, setting := range role.Settings {
+			if setting.Id != o.config.Authorization.AdminPermission {
+				continue
+			}
+			p := setting.GetPermissionValue()
+			if p != nil && p.Operation == settings.Permission_OPERATION_READWRITE && p.Constraint == settings.Permission_CONSTRAINT_ALL {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// subadminScope returns the groups administered by the current user if the request was authorized for a subadmin
+func subadminScope(ctx context.Context) (map[string]bool, bool) {
+	groups, ok := ctx.Value(subadminScopeKey).(map[string]bool)
+	return groups, ok
+}
+
+// currentUser returns the authenticated user of the request
+func currentUser(ctx context.Context) (*userpb.User, bool) {
+	u, ok := user.ContextGetUser(ctx)
+	if !ok || u.GetId().GetOpaqueId() == "" {
+		return nil, false
+	}
+	return u, true
+}
+
+// isSelf checks if the id refers to the user
+func isSelf(u *userpb.User, id string) bool {
+	return id != "" && id == u.Id.OpaqueId
+}
@@ -0,0 +1,112 @@
+package svc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/render"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+const (
+	// maxBatchSize limits the number of users created with a single request
+	maxBatchSize = 1000
+	// batchConcurrency is the number of users created at the same time
+	batchConcurrency = 10
+)
+
+// userBatch is the json body of a batch provisioning request
+type userBatch struct {
+	Users []*userDefinition `json:"users"`
+	// Atomic deletes all created users again if one of them could not be created
+	Atomic bool `json:"atomic"`
+}
+
+// AddUsers creates several users concurrently. The response contains an ocs status for every user, in the order
+// of the request.
+func (o Ocs) AddUsers(w http.ResponseWriter, r *http.Request) {
+	batch := &userBatch{}
+	if err := json.NewDecoder(r.Body).Decode(batch); err != nil {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "invalid batch: "+err.Error()))
+		return
+	}
+	if len(batch.Users) == 0 {
+		render.Render(w, r, response.ErrRender(data.MetaInvalidInput.StatusCode, "no users specified"))
+		return
+	}
+	if len(batch.Users) > maxBatchSize {
+		render.Render(w, r, response.ErrRender(data.MetaInvalidInput.StatusCode, fmt.Sprintf("too many users, the maximum is %d", maxBatchSize)))
+		return
+	}
+
+	results := make([]*data.BatchResult, len(batch.Users))
+	created := make([]*accounts.Account, len(batch.Users))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, batchConcurrency)
+	for i := range batch.Users {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			u := batch.Users[i]
+			if u == nil {
+				results[i] = batchFailure("", data.MetaInvalidInput.StatusCode, "missing user")
+				return
+			}
+			account, oerr := o.createUser(r.Context(), u)
+			if oerr != nil {
+				results[i] = batchFailure(u.UserID, oerr.code, oerr.message)
+				return
+			}
+			created[i] = account
+			results[i] = &data.BatchResult{
+				UserID:     account.Id,
+				Status:     data.MetaOK.Status,
+				StatusCode: data.MetaOK.StatusCode,
+				Message:    data.MetaOK.Message,
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, res := range results {
+		if res.StatusCode != data.MetaOK.StatusCode {
+			failed++
+		}
+	}
+
+	if batch.Atomic && failed > 0 {
+		for i, account := range created {
+			if account == nil {
+				continue
+			}
+			if _, err := o.getAccountService().DeleteAccount(r.Context(), &accounts.DeleteAccountRequest{Id: account.Id}); err != nil {
+				o.logger.Error().Err(err).Str("userid", account.Id).Msg("could not roll back created user")
+				results[i] = batchFailure(account.Id, data.MetaServerError.StatusCode, "user was created but could not be rolled back")
+				continue
+			}
+			results[i] = batchFailure(account.Id, data.MetaFailure.StatusCode, "rolled back because other users could not be created")
+		}
+	}
+
+	o.logger.Debug().Int("count", len(results)).Int("failed", failed).Bool("atomic", batch.Atomic).Msg("added users")
+	render.Render(w, r, response.DataRender(&data.BatchResults{Users: results}))
+}
+
+func batchFailure(userid string, code int, message string) *data.BatchResult {
+	return &data.BatchResult{
+		UserID:     userid,
+		Status:     "error",
+		StatusCode: code,
+		Message:    message,
+	}
+}
@@ -0,0 +1,155 @@
+package svc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+	"github.com/owncloud/ocis-ocs/pkg/version"
+)
+
+// GetCapabilities renders the ocs capabilities endpoint
+func (o Ocs) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, response.DataRender(&data.CapabilitiesData{
+		Capabilities: o.capabilities,
+		Version:      versionData(),
+	}))
+}
+
+// newCapabilities fills every capability section that has not been configured with its default.
+// Sections are only replaced as a whole: once a section is configured all of its values are taken from the config.
+func newCapabilities(cfg data.Capabilities) *data.Capabilities {
+	c := cfg
+
+	if c.Core == nil {
+		c.Core = &data.CapabilitiesCore{
+			PollInterval:      60,
+			WebdavRoot:        "remote.php/webdav",
+			SupportURLSigning: true,
+		}
+	}
+	if c.Core.Status == nil {
+		c.Core.Status = &data.Status{
+			Installed:     true,
+			Version:       version.String,
+			VersionString: version.String,
+			Edition:       "community",
+			ProductName:   "ocis",
+		}
+	}
+
+	if c.Checksums == nil {
+		c.Checksums = &data.CapabilitiesChecksums{
+			SupportedTypes:      []string{"SHA256"},
+			PreferredUploadType: "SHA256",
+		}
+	}
+
+	if c.Files == nil {
+		c.Files = &data.CapabilitiesFiles{
+			PrivateLinks:     true,
+			BigFileChunking:  true,
+			Undelete:         true,
+			Versioning:       true,
+			BlacklistedFiles: []string{".htaccess"},
+		}
+	}
+	if c.Files.TusSupport == nil {
+		c.Files.TusSupport = &data.CapabilitiesFilesTusSupport{
+			Version:   "1.0.0",
+			Resumable: "1.0.0",
+			Extension: "creation,creation-with-upload",
+		}
+	}
+
+	if c.Dav == nil {
+		c.Dav = &data.CapabilitiesDav{
+			Chunking: "1.0",
+			Trashbin: "1.0",
+			Reports:  []string{"search-files"},
+		}
+	}
+
+	if c.FilesSharing == nil {
+		c.FilesSharing = &data.CapabilitiesFilesSharing{
+			APIEnabled:                    true,
+			Resharing:                     true,
+			GroupSharing:                  true,
+			AutoAcceptShare:               true,
+			ShareWithGroupMembersOnly:     true,
+			ShareWithMembershipGroupsOnly: true,
+			SearchMinLength:               2,
+			DefaultPermissions:            31,
+		}
+	}
+	if c.FilesSharing.UserEnumeration == nil {
+		c.FilesSharing.UserEnumeration = &data.CapabilitiesFilesSharingUserEnumeration{
+			Enabled:          true,
+			GroupMembersOnly: true,
+		}
+	}
+	if c.FilesSharing.Federation == nil {
+		c.FilesSharing.Federation = &data.CapabilitiesFilesSharingFederation{}
+	}
+	if c.FilesSharing.Public == nil {
+		c.FilesSharing.Public = &data.CapabilitiesFilesSharingPublic{
+			Enabled:            true,
+			SendMail:           true,
+			SocialShare:        true,
+			Upload:             true,
+			Multiple:           true,
+			SupportsUploadOnly: true,
+		}
+	}
+	if c.FilesSharing.Public.Password == nil {
+		c.FilesSharing.Public.Password = &data.CapabilitiesFilesSharingPublicPassword{}
+	}
+	if c.FilesSharing.Public.Password.EnforcedFor == nil {
+		c.FilesSharing.Public.Password.EnforcedFor = &data.CapabilitiesFilesSharingPublicPasswordEnforcedFor{}
+	}
+	if c.FilesSharing.Public.ExpireDate == nil {
+		c.FilesSharing.Public.ExpireDate = &data.CapabilitiesFilesSharingPublicExpireDate{}
+	}
+	if c.FilesSharing.User == nil {
+		c.FilesSharing.User = &data.CapabilitiesFilesSharingUser{
+			SendMail: true,
+		}
+	}
+
+	if c.Notifications == nil {
+		c.Notifications = &data.CapabilitiesNotifications{
+			Endpoints: notificationEndpoints,
+		}
+	}
+
+	return &c
+}
+
+// versionData parses the build version into the oc10 version format.
+// Versions that are not semver, e.g. a commit hash on testing builds, only set the version string.
+func versionData() *data.Version {
+	v := &data.Version{
+		String:  version.String,
+		Edition: "community",
+	}
+
+	parts := strings.SplitN(strings.SplitN(strings.TrimPrefix(version.String, "v"), "-", 2)[0], ".", 3)
+	if len(parts) != 3 {
+		return v
+	}
+
+	numbers := make([]int, 3)
+	for i := range parts {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return v
+		}
+		numbers[i] = n
+	}
+
+	v.Major, v.Minor, v.Micro = numbers[0], numbers[1], numbers[2]
+	return v
+}
@@ -0,0 +1,100 @@
+package svc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/micro/go-micro/v2/client"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"go.opencensus.io/trace"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/metrics"
+	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
+)
+
+// backendClient records the latency and error codes of the calls to backend services and creates a child span
+// for every call, so that slow backends can be told apart from slow handlers.
+type backendClient struct {
+	client.Client
+	// metrics may be nil, then only spans are created
+	metrics *metrics.Metrics
+}
+
+// newBackendClient wraps the micro client used for the backend services
+func newBackendClient(c client.Client, m *metrics.Metrics) client.Client {
+	return backendClient{
+		Client:  c,
+		metrics: m,
+	}
+}
+
+// Call implements the client.Client interface.
+func (c backendClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	ctx, span := trace.StartSpan(ctx, req.Service()+"/"+req.Endpoint(), trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.AddAttributes(
+		trace.StringAttribute("rpc.service", req.Service()),
+		trace.StringAttribute("rpc.method", req.Endpoint()),
+	)
+	span.AddAttributes(requestIDs(req.Body())...)
+
+	start := time.Now()
+	err := c.Client.Call(ctx, req, rsp, opts...)
+
+	code := callCode(err)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: code})
+	}
+	if c.metrics != nil {
+		c.metrics.BackendCalls.WithLabelValues(req.Service(), req.Endpoint(), code).Inc()
+		c.metrics.BackendDuration.WithLabelValues(req.Service(), req.Endpoint()).Observe(time.Since(start).Seconds())
+	}
+	return err
+}
+
+// callCode returns the micro error code of a failed call, OK for successful calls
+func callCode(err error) string {
+	if err == nil {
+		return "OK"
+	}
+	if code := merrors.FromError(err).Code; code != 0 {
+		return strconv.Itoa(int(code))
+	}
+	return "unknown"
+}
+
+// requestIDs returns the ids of the accounts, groups and store records a request refers to.
+// Queries and values are never added to spans, they may contain passwords.
+func requestIDs(body interface{}) []trace.Attribute {
+	var attrs []trace.Attribute
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, trace.StringAttribute(key, value))
+		}
+	}
+
+	if b, ok := body.(interface{ GetId() string }); ok {
+		add("id", b.GetId())
+	}
+	if b, ok := body.(interface{ GetAccountId() string }); ok {
+		add("account_id", b.GetAccountId())
+	}
+	if b, ok := body.(interface{ GetGroupId() string }); ok {
+		add("group_id", b.GetGroupId())
+	}
+	if b, ok := body.(interface{ GetAccount() *accounts.Account }); ok {
+		add("account_id", b.GetAccount().GetId())
+	}
+	if b, ok := body.(interface{ GetGroup() *accounts.Group }); ok {
+		add("group_id", b.GetGroup().GetId())
+	}
+	if b, ok := body.(interface{ GetKey() string }); ok {
+		add("key", b.GetKey())
+	}
+	if b, ok := body.(interface{ GetRecord() *storepb.Record }); ok {
+		add("key", b.GetRecord().GetKey())
+	}
+	return attrs
+}
@@ -0,0 +1,102 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/micro/go-micro/v2/client"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/trace"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/metrics"
+)
+
+// fakeClient answers every call with the configured error. Requests are built by the embedded client.
+type fakeClient struct {
+	client.Client
+	err error
+}
+
+func (c fakeClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return c.err
+}
+
+// spanRecorder collects the exported spans
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func TestBackendClient(t *testing.T) {
+	const (
+		service = "com.owncloud.api.accounts"
+		method  = "AccountsService.GetAccount"
+	)
+
+	recorder := &spanRecorder{}
+	trace.RegisterExporter(recorder)
+	defer trace.UnregisterExporter(recorder)
+
+	m := metrics.New()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(m.BackendCalls, m.BackendDuration)
+
+	testData := []struct {
+		err         error
+		code        string
+		description string
+	}{
+		{nil, "OK", "successful call"},
+		{merrors.NotFound(service, "account not found"), "404", "micro error"},
+		{errors.New("connection refused"), "unknown", "error without code"},
+	}
+
+	// backend spans are children of the request span, which decides on the sampling
+	ctx, parent := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+	for _, data := range testData {
+		c := newBackendClient(fakeClient{Client: defaultClient, err: data.err}, m)
+		_, err := accounts.NewAccountsService(service, c).GetAccount(ctx, &accounts.GetAccountRequest{Id: "einstein"})
+		assert.Equal(t, data.err, err, data.description)
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.BackendCalls.WithLabelValues(service, method, data.code)), data.description)
+	}
+	parent.End()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "ocis_ocs_backend_call_duration_seconds" {
+			continue
+		}
+		assert.Len(t, family.GetMetric(), 1, "durations are recorded per service and method")
+		assert.Equal(t, uint64(len(testData)), family.GetMetric()[0].GetHistogram().GetSampleCount())
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	calls := 0
+	for _, span := range recorder.spans {
+		if span.Name != service+"/"+method {
+			continue
+		}
+		calls++
+		assert.Equal(t, parent.SpanContext().TraceID, span.TraceID)
+		assert.Equal(t, service, span.Attributes["rpc.service"])
+		assert.Equal(t, method, span.Attributes["rpc.method"])
+		assert.Equal(t, "einstein", span.Attributes["id"])
+	}
+	assert.Equal(t, len(testData), calls, "a span is expected for every call")
+}
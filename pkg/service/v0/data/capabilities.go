@@ -24,7 +24,7 @@ func (c ocsBool) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	return e.EncodeElement("0", start)
 }
 
-// CapabilitiesData TODO document
+// CapabilitiesData holds the payload for a GetCapabilities response
 type CapabilitiesData struct {
 	Capabilities *Capabilities `json:"capabilities" xml:"capabilities"`
 	Version      *Version      `json:"version" xml:"version"`
@@ -37,7 +37,9 @@ type Capabilities struct {
 	Files         *CapabilitiesFiles         `json:"files" xml:"files" mapstructure:"files"`
 	Dav           *CapabilitiesDav           `json:"dav" xml:"dav"`
 	FilesSharing  *CapabilitiesFilesSharing  `json:"files_sharing" xml:"files_sharing" mapstructure:"files_sharing"`
-	Notifications *CapabilitiesNotifications `json:"notifications" xml:"notifications"`
+	Notifications *CapabilitiesNotifications `json:"notifications,omitempty" xml:"notifications,omitempty"`
+	// PasswordPolicy is always derived from the password policy configuration
+	PasswordPolicy *CapabilitiesPasswordPolicy `json:"password_policy,omitempty" xml:"password_policy,omitempty"`
 }
 
 // CapabilitiesCore holds webdav config
@@ -134,9 +136,11 @@ type CapabilitiesFilesSharingPublicPasswordEnforcedFor struct {
 	UploadOnly ocsBool `json:"upload_only" xml:"upload_only,omitempty" mapstructure:"upload_only"`
 }
 
-// CapabilitiesFilesSharingPublicExpireDate TODO document
+// CapabilitiesFilesSharingPublicExpireDate holds the default and maximum expiration of public links
 type CapabilitiesFilesSharingPublicExpireDate struct {
-	Enabled ocsBool `json:"enabled" xml:"enabled"`
+	Enabled  ocsBool `json:"enabled" xml:"enabled"`
+	Days     int     `json:"days,omitempty" xml:"days,omitempty"`
+	Enforced ocsBool `json:"enforced,omitempty" xml:"enforced,omitempty"`
 }
 
 // CapabilitiesFilesSharingUser TODO document
@@ -161,6 +165,18 @@ type CapabilitiesNotifications struct {
 	Endpoints []string `json:"ocs-endpoints" xml:"ocs-endpoints>element" mapstructure:"endpoints"`
 }
 
+// CapabilitiesPasswordPolicy holds the rules passwords of users have to follow, zero values are not enforced
+type CapabilitiesPasswordPolicy struct {
+	MinCharacters        int     `json:"min_characters" xml:"min_characters"`
+	MaxCharacters        int     `json:"max_characters" xml:"max_characters"`
+	MinLowerCase         int     `json:"min_lowercase_characters" xml:"min_lowercase_characters"`
+	MinUpperCase         int     `json:"min_uppercase_characters" xml:"min_uppercase_characters"`
+	MinDigits            int     `json:"min_digits" xml:"min_digits"`
+	MinSpecialCharacters int     `json:"min_special_characters" xml:"min_special_characters"`
+	DisallowedPasswords  ocsBool `json:"disallowed_passwords" xml:"disallowed_passwords"`
+	DisallowUsername     ocsBool `json:"disallow_username" xml:"disallow_username"`
+}
+
 // Version holds version information
 type Version struct {
 	Major   int    `json:"major" xml:"major"`
@@ -4,3 +4,16 @@ package data
 type Groups struct {
 	Groups []string `json:"groups" xml:"groups>element"`
 }
+
+// GroupDetails holds group records for the detailed group listings
+type GroupDetails struct {
+	Groups []*Group `json:"groups" xml:"groups>element"`
+}
+
+// Group holds the details of a group
+type Group struct {
+	ID          string `json:"id" xml:"id"`
+	DisplayName string `json:"displayname" xml:"displayname"`
+	GIDNumber   int64  `json:"gidnumber" xml:"gidnumber"`
+	MemberCount int    `json:"membercount" xml:"membercount"`
+}
@@ -21,6 +21,9 @@ var MetaInvalidInput = Meta{Status: "", StatusCode: 102, Message: "Invalid Input
 // MetaBadRequest is used for unknown errors
 var MetaBadRequest = Meta{Status: "error", StatusCode: 400, Message: "Bad Request"}
 
+// MetaForbidden is returned when the user is not allowed to perform an action
+var MetaForbidden = Meta{Status: "error", StatusCode: 403, Message: "Forbidden"}
+
 // MetaServerError is returned on server errors
 var MetaServerError = Meta{Status: "error", StatusCode: 996, Message: "Server Error"}
 
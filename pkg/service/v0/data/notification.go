@@ -0,0 +1,15 @@
+package data
+
+// Notification holds the payload for the notifications app, mimicking the oc10 notification format
+type Notification struct {
+	NotificationID string        `json:"notification_id" xml:"notification_id"`
+	App            string        `json:"app" xml:"app"`
+	User           string        `json:"user" xml:"user"`
+	Datetime       string        `json:"datetime" xml:"datetime"`
+	ObjectType     string        `json:"object_type" xml:"object_type"`
+	ObjectID       string        `json:"object_id" xml:"object_id"`
+	Subject        string        `json:"subject" xml:"subject"`
+	Message        string        `json:"message" xml:"message"`
+	Link           string        `json:"link" xml:"link"`
+	Actions        []interface{} `json:"actions" xml:"actions>element"`
+}
@@ -0,0 +1,64 @@
+package data
+
+const (
+	// ShareTypeUser is used for shares with a single user
+	ShareTypeUser = 0
+	// ShareTypeGroup is used for shares with a group
+	ShareTypeGroup = 1
+	// ShareTypePublicLink is used for public links
+	ShareTypePublicLink = 3
+)
+
+const (
+	// PermissionRead allows to read and download the shared resource
+	PermissionRead = 1
+	// PermissionUpdate allows to change the contents of the shared resource
+	PermissionUpdate = 2
+	// PermissionCreate allows to create new files and folders in a shared folder
+	PermissionCreate = 4
+	// PermissionDelete allows to delete files and folders in a shared folder
+	PermissionDelete = 8
+	// PermissionShare allows to reshare the shared resource
+	PermissionShare = 16
+	// PermissionAll combines all permissions
+	PermissionAll = PermissionRead | PermissionUpdate | PermissionCreate | PermissionDelete | PermissionShare
+)
+
+const (
+	// ShareStateAccepted is used for received shares that have been accepted
+	ShareStateAccepted = 0
+	// ShareStatePending is used for received shares that have not been accepted yet
+	ShareStatePending = 1
+	// ShareStateRejected is used for received shares that have been rejected
+	ShareStateRejected = 2
+)
+
+// ShareData holds the payload for the share endpoints, mimicking the oc10 share format
+type ShareData struct {
+	ID                   string `json:"id" xml:"id"`
+	ShareType            int    `json:"share_type" xml:"share_type"`
+	UIDOwner             string `json:"uid_owner" xml:"uid_owner"`
+	DisplaynameOwner     string `json:"displayname_owner" xml:"displayname_owner"`
+	Permissions          int    `json:"permissions" xml:"permissions"`
+	STime                uint64 `json:"stime" xml:"stime"`
+	Parent               string `json:"parent" xml:"parent"`
+	Expiration           string `json:"expiration" xml:"expiration"`
+	Token                string `json:"token" xml:"token"`
+	UIDFileOwner         string `json:"uid_file_owner" xml:"uid_file_owner"`
+	DisplaynameFileOwner string `json:"displayname_file_owner" xml:"displayname_file_owner"`
+	Path                 string `json:"path" xml:"path"`
+	ItemType             string `json:"item_type" xml:"item_type"`
+	MimeType             string `json:"mimetype" xml:"mimetype"`
+	StorageID            string `json:"storage_id" xml:"storage_id"`
+	Storage              uint64 `json:"storage" xml:"storage"`
+	ItemSource           string `json:"item_source" xml:"item_source"`
+	FileSource           string `json:"file_source" xml:"file_source"`
+	FileParent           string `json:"file_parent" xml:"file_parent"`
+	FileTarget           string `json:"file_target" xml:"file_target"`
+	ShareWith            string `json:"share_with,omitempty" xml:"share_with,omitempty"`
+	ShareWithDisplayname string `json:"share_with_displayname,omitempty" xml:"share_with_displayname,omitempty"`
+	MailSend             int    `json:"mail_send" xml:"mail_send"`
+	State                int    `json:"state" xml:"state"`
+	Name                 string `json:"name,omitempty" xml:"name,omitempty"`
+	URL                  string `json:"url,omitempty" xml:"url,omitempty"`
+}
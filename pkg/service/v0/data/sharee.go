@@ -0,0 +1,29 @@
+package data
+
+// Sharees holds the payload for a sharees search, split into exact and other matches
+type Sharees struct {
+	Exact   *ExactSharees `json:"exact" xml:"exact"`
+	Users   []*Sharee     `json:"users" xml:"users>element"`
+	Groups  []*Sharee     `json:"groups" xml:"groups>element"`
+	Remotes []*Sharee     `json:"remotes" xml:"remotes>element"`
+}
+
+// ExactSharees holds the sharees exactly matching the search term
+type ExactSharees struct {
+	Users   []*Sharee `json:"users" xml:"users>element"`
+	Groups  []*Sharee `json:"groups" xml:"groups>element"`
+	Remotes []*Sharee `json:"remotes" xml:"remotes>element"`
+}
+
+// Sharee is a possible share recipient
+type Sharee struct {
+	Label string       `json:"label" xml:"label"`
+	Value *ShareeValue `json:"value" xml:"value"`
+}
+
+// ShareeValue holds the parameters needed to create a share with a sharee
+type ShareeValue struct {
+	ShareType               int    `json:"shareType" xml:"shareType"`
+	ShareWith               string `json:"shareWith" xml:"shareWith"`
+	ShareWithAdditionalInfo string `json:"shareWithAdditionalInfo,omitempty" xml:"shareWithAdditionalInfo,omitempty"`
+}
@@ -5,6 +5,11 @@ type Users struct {
 	Users []string `json:"users" xml:"users>element"`
 }
 
+// UserDetails holds full user records for the detailed user listing
+type UserDetails struct {
+	Users []*User `json:"users" xml:"users>element"`
+}
+
 // User holds the payload for a GetUser response
 type User struct {
 	// TODO needs better naming, clarify if we need a userid, a username or both
@@ -14,9 +19,24 @@ type User struct {
 	DisplayName       string `json:"display-name" xml:"display-name"`
 	LegacyDisplayName string `json:"displayname" xml:"displayname"`
 	Email             string `json:"email" xml:"email"`
-	Quota             *Quota `json:"quota" xml:"quota"`
+	Quota             *Quota `json:"quota,omitempty" xml:"quota,omitempty"`
 	UIDNumber         int64  `json:"uidnumber" xml:"uidnumber"`
 	GIDNumber         int64  `json:"gidnumber" xml:"gidnumber"`
+	// Groups are only reported when a user is created
+	Groups []string `json:"groups,omitempty" xml:"groups>element,omitempty"`
+}
+
+// BatchResults holds the results of a batch provisioning request, in the order of the request
+type BatchResults struct {
+	Users []*BatchResult `json:"users" xml:"users>element"`
+}
+
+// BatchResult holds the ocs status of a single user of a batch provisioning request
+type BatchResult struct {
+	UserID     string `json:"id" xml:"id"`
+	Status     string `json:"status" xml:"status"`
+	StatusCode int    `json:"statuscode" xml:"statuscode"`
+	Message    string `json:"message" xml:"message"`
 }
 
 // Quota holds quota information
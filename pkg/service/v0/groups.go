@@ -1,8 +1,13 @@
 package svc
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
@@ -11,8 +16,11 @@ import (
 	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
 	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
 	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
+var groupNamePattern = regexp.MustCompile(`^[a-zA-Z0-9 _.@\-']+$`)
+
 // ListUserGroups lists a users groups
 func (o Ocs) ListUserGroups(w http.ResponseWriter, r *http.Request) {
 	userid := chi.URLParam(r, "userid")
@@ -30,6 +38,23 @@ func (o Ocs) ListUserGroups(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("details") == "true" {
+		details := make([]*data.Group, 0, len(account.MemberOf))
+		for i := range account.MemberOf {
+			// the groups of an account do not contain their members
+			group, err := o.getGroupsService().GetGroup(r.Context(), &accounts.GetGroupRequest{Id: account.MemberOf[i].Id})
+			if err != nil {
+				o.logger.Error().Err(err).Str("userid", userid).Str("groupid", account.MemberOf[i].Id).Msg("could not get group")
+				render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+				return
+			}
+			details = append(details, groupData(group))
+		}
+		o.logger.Debug().Int("count", len(details)).Str("userid", userid).Msg("listing group details for user")
+		render.Render(w, r, response.DataRender(&data.GroupDetails{Groups: details}))
+		return
+	}
+
 	groups := []string{}
 	for i := range account.MemberOf {
 		groups = append(groups, account.MemberOf[i].Id)
@@ -41,9 +66,8 @@ func (o Ocs) ListUserGroups(w http.ResponseWriter, r *http.Request) {
 
 // AddToGroup adds a user to a group
 func (o Ocs) AddToGroup(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
 	userid := chi.URLParam(r, "userid")
-	groupid := r.PostForm.Get("groupid")
+	groupid := groupIDParam(r)
 
 	if groupid == "" {
 		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "empty group assignment: unspecified group"))
@@ -73,7 +97,12 @@ func (o Ocs) AddToGroup(w http.ResponseWriter, r *http.Request) {
 // RemoveFromGroup removes a user from a group
 func (o Ocs) RemoveFromGroup(w http.ResponseWriter, r *http.Request) {
 	userid := chi.URLParam(r, "userid")
-	groupid := r.URL.Query().Get("groupid")
+	groupid := groupIDParam(r)
+
+	if groupid == "" {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "empty group assignment: unspecified group"))
+		return
+	}
 
 	_, err := o.getGroupsService().RemoveMember(r.Context(), &accounts.RemoveMemberRequest{
 		AccountId: userid,
@@ -95,40 +124,204 @@ func (o Ocs) RemoveFromGroup(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, response.DataRender(struct{}{}))
 }
 
-// ListGroups lists all groups
+// ListGroups lists all groups. The search matches the beginning of the id, name and display name, ignoring case.
+// With details=true the display name, gid number and member count of the groups are returned instead of ids.
 func (o Ocs) ListGroups(w http.ResponseWriter, r *http.Request) {
-	search := r.URL.Query().Get("search")
-	query := ""
-	if search != "" {
-		query = fmt.Sprintf("id eq '%s' or on_premises_sam_account_name eq '%s'", escapeValue(search), escapeValue(search))
+	p, ok := paginationParams(w, r)
+	if !ok {
+		return
 	}
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
+	details := r.URL.Query().Get("details") == "true"
 
 	res, err := o.getGroupsService().ListGroups(r.Context(), &accounts.ListGroupsRequest{
-		Query: query,
+		Query: searchQuery(search, "id", "on_premises_sam_account_name", "display_name"),
 	})
-
 	if err != nil {
-		o.logger.Err(err).Msg("could not list users")
-		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list users"))
+		o.logger.Err(err).Msg("could not list groups")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list groups"))
 		return
 	}
 
-	groups := []string{}
-	for i := range res.Groups {
-		groups = append(groups, res.Groups[i].Id)
+	if !details {
+		groups := make([]string, 0, len(res.Groups))
+		for _, g := range res.Groups {
+			groups = append(groups, g.Id)
+		}
+		p.render(w, r, &data.Groups{Groups: p.page(groups)}, len(groups))
+		return
 	}
 
-	render.Render(w, r, response.DataRender(&data.Groups{Groups: groups}))
+	sort.Slice(res.Groups, func(i, j int) bool {
+		return res.Groups[i].Id < res.Groups[j].Id
+	})
+	start, end := p.bounds(len(res.Groups))
+	groups := make([]*data.Group, 0, end-start)
+	for _, g := range res.Groups[start:end] {
+		groups = append(groups, groupData(g))
+	}
+	p.render(w, r, &data.GroupDetails{Groups: groups}, len(res.Groups))
+}
+
+// groupData converts a group to its detailed representation
+func groupData(g *accounts.Group) *data.Group {
+	displayName := g.DisplayName
+	if displayName == "" {
+		displayName = g.OnPremisesSamAccountName
+	}
+	return &data.Group{
+		ID:          g.Id,
+		DisplayName: displayName,
+		GIDNumber:   g.GidNumber,
+		MemberCount: len(g.Members),
+	}
 }
 
 // AddGroup adds a group
 func (o Ocs) AddGroup(w http.ResponseWriter, r *http.Request) {
-	render.Render(w, r, response.ErrRender(data.MetaUnknownError.StatusCode, "not implemented"))
+	groupid := r.PostFormValue("groupid")
+	displayname := r.PostFormValue("displayname")
+	gidnumber := r.PostFormValue("gidnumber")
+
+	if !validGroupName(groupid) {
+		render.Render(w, r, response.ErrRender(103, "Invalid group name"))
+		return
+	}
+	if displayname == "" {
+		displayname = groupid
+	}
+	var gid int64
+	if gidnumber != "" {
+		var err error
+		if gid, err = strconv.ParseInt(gidnumber, 10, 64); err != nil || gid <= 0 {
+			render.Render(w, r, response.ErrRender(103, "Invalid gidnumber "+gidnumber))
+			return
+		}
+	}
+
+	exists, err := o.groupExists(r.Context(), groupid, "")
+	if err != nil {
+		o.logger.Error().Err(err).Str("groupid", groupid).Msg("could not check if group exists")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		return
+	}
+	if exists {
+		render.Render(w, r, response.ErrRender(data.MetaInvalidInput.StatusCode, "group exists"))
+		return
+	}
+
+	group, err := o.getGroupsService().CreateGroup(r.Context(), &accounts.CreateGroupRequest{
+		Group: &accounts.Group{
+			Id:                       groupid,
+			OnPremisesSamAccountName: groupid,
+			DisplayName:              displayname,
+			GidNumber:                gid,
+		},
+	})
+	if err != nil {
+		merr := merrors.FromError(err)
+		if merr.Code == http.StatusBadRequest {
+			render.Render(w, r, response.ErrRender(103, merr.Detail))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("groupid", groupid).Msg("could not add group")
+		return
+	}
+
+	o.logger.Debug().Interface("group", group).Msg("added group")
+	render.Render(w, r, response.DataRender(struct{}{}))
 }
 
-// DeleteGroup deletes a group
-func (o Ocs) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+// EditGroup renames a group or changes its display name. The group can be given by id or name. Renaming only
+// changes the name, the id stays the same, so memberships, subadmin assignments and shares are kept.
+func (o Ocs) EditGroup(w http.ResponseWriter, r *http.Request) {
 	groupid := chi.URLParam(r, "groupid")
+	key := r.PostFormValue("key")
+	value := r.PostFormValue("value")
+
+	group, ok := o.groupParam(w, r, groupid)
+	if !ok {
+		return
+	}
+	req := accounts.UpdateGroupRequest{
+		Group: &accounts.Group{
+			Id: group.Id,
+		},
+	}
+
+	switch key {
+	case "name":
+		if !validGroupName(value) {
+			render.Render(w, r, response.ErrRender(103, "Invalid group name"))
+			return
+		}
+		exists, err := o.groupExists(r.Context(), value, group.Id)
+		if err != nil {
+			o.logger.Error().Err(err).Str("groupid", group.Id).Msg("could not check if group exists")
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+			return
+		}
+		if exists {
+			render.Render(w, r, response.ErrRender(data.MetaInvalidInput.StatusCode, "group exists"))
+			return
+		}
+		req.Group.OnPremisesSamAccountName = value
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"OnPremisesSamAccountName"}}
+	case "displayname":
+		if value == "" {
+			render.Render(w, r, response.ErrRender(103, "Invalid display name"))
+			return
+		}
+		req.Group.DisplayName = value
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"DisplayName"}}
+	default:
+		render.Render(w, r, response.ErrRender(103, "unknown key '"+key+"'"))
+		return
+	}
+
+	updated, err := o.getGroupsService().UpdateGroup(r.Context(), &req)
+	if err != nil {
+		merr := merrors.FromError(err)
+		switch merr.Code {
+		case http.StatusNotFound:
+			render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested group could not be found"))
+		case http.StatusBadRequest:
+			render.Render(w, r, response.ErrRender(103, merr.Detail))
+		default:
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("groupid", group.Id).Msg("could not edit group")
+		return
+	}
+
+	o.logger.Debug().Interface("group", updated).Msg("updated group")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
+// groupParam looks up the group of a request by id or name. If it cannot be found an ocs error is rendered and
+// false is returned.
+func (o Ocs) groupParam(w http.ResponseWriter, r *http.Request, idOrName string) (*accounts.Group, bool) {
+	group, err := o.lookupGroup(r.Context(), idOrName)
+	if err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested group could not be found"))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("groupid", idOrName).Msg("could not get group")
+		return nil, false
+	}
+	return group, true
+}
+
+// DeleteGroup deletes a group, given by id or name
+func (o Ocs) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := o.groupParam(w, r, chi.URLParam(r, "groupid"))
+	if !ok {
+		return
+	}
+	groupid := group.Id
 
 	_, err := o.getGroupsService().DeleteGroup(r.Context(), &accounts.DeleteGroupRequest{
 		Id: groupid,
@@ -145,14 +338,25 @@ func (o Ocs) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := o.removeGroupSubadmins(r.Context(), groupid); err != nil {
+		o.logger.Error().Err(err).Str("groupid", groupid).Msg("could not remove subadmin assignments of deleted group")
+	}
+
 	o.logger.Debug().Str("groupid", groupid).Msg("removed group")
 	render.Render(w, r, response.DataRender(struct{}{}))
 }
 
-// GetGroupMembers lists all members of a group
+// GetGroupMembers lists all members of a group, given by id or name
 func (o Ocs) GetGroupMembers(w http.ResponseWriter, r *http.Request) {
-
-	groupid := chi.URLParam(r, "groupid")
+	p, ok := paginationParams(w, r)
+	if !ok {
+		return
+	}
+	group, ok := o.groupParam(w, r, chi.URLParam(r, "groupid"))
+	if !ok {
+		return
+	}
+	groupid := group.Id
 
 	res, err := o.getGroupsService().ListMembers(r.Context(), &accounts.ListMembersRequest{Id: groupid})
 
@@ -173,5 +377,48 @@ func (o Ocs) GetGroupMembers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	o.logger.Error().Err(err).Int("count", len(members)).Str("groupid", groupid).Msg("listing group members")
-	render.Render(w, r, response.DataRender(&data.Users{Users: members}))
+	p.render(w, r, &data.Users{Users: p.page(members)}, len(members))
+}
+
+// groupExists checks if a group other than the excluded one uses the name as id or name
+func (o Ocs) groupExists(ctx context.Context, name, exclude string) (bool, error) {
+	res, err := o.getGroupsService().ListGroups(ctx, &accounts.ListGroupsRequest{
+		Query: fmt.Sprintf("id eq '%s' or on_premises_sam_account_name eq '%s'", escapeValue(name), escapeValue(name)),
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, g := range res.Groups {
+		if g.Id != exclude {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validGroupName checks a group name with the characters oc10 allows in user names
+func validGroupName(name string) bool {
+	return groupNamePattern.MatchString(name)
+}
+
+// lookupGroup finds a group by its id or, as a fallback, by its name
+func (o Ocs) lookupGroup(ctx context.Context, idOrName string) (*accounts.Group, error) {
+	group, err := o.getGroupsService().GetGroup(ctx, &accounts.GetGroupRequest{Id: idOrName})
+	if err == nil {
+		return group, nil
+	}
+	if merrors.FromError(err).Code != http.StatusNotFound {
+		return nil, err
+	}
+
+	res, err := o.getGroupsService().ListGroups(ctx, &accounts.ListGroupsRequest{
+		Query: fmt.Sprintf("on_premises_sam_account_name eq '%s'", escapeValue(idOrName)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Groups) != 1 {
+		return nil, merrors.NotFound("com.owncloud.api.ocs", "group %s not found", idOrName)
+	}
+	return res.Groups[0], nil
 }
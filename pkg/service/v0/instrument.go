@@ -1,9 +1,17 @@
 package svc
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
 
 	"github.com/owncloud/ocis-ocs/pkg/metrics"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
 )
 
 // NewInstrument returns a service that instruments metrics.
@@ -21,10 +29,79 @@ type instrument struct {
 
 // ServeHTTP implements the Service interface.
 func (i instrument) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	i.next.ServeHTTP(w, r)
+	start := time.Now()
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	r, rctx, ocsStatus := observe(r)
+
+	i.next.ServeHTTP(ww, r)
+
+	labels := []string{
+		routePattern(rctx),
+		ww.Header().Get("Ocs-Api-Version"),
+		responseFormat(ww.Header()),
+		strconv.Itoa(httpStatus(ww)),
+		ocsStatusLabel(*ocsStatus),
+	}
+	i.metrics.Requests.WithLabelValues(labels...).Inc()
+	i.metrics.Duration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
 }
 
 // GetConfig implements the Service interface.
 func (i instrument) GetConfig(w http.ResponseWriter, r *http.Request) {
 	i.next.GetConfig(w, r)
 }
+
+// observe prepares the request, so that the route pattern and the ocs status code can be read after it was served.
+// The router uses a route context that is already present instead of creating its own, so wrapping services
+// share the route context and status recorder.
+func observe(r *http.Request) (*http.Request, *chi.Context, *int) {
+	ctx := r.Context()
+	rctx, ok := ctx.Value(chi.RouteCtxKey).(*chi.Context)
+	if !ok {
+		rctx = chi.NewRouteContext()
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+	}
+	ocsStatus := response.StatusRecorder(ctx)
+	if ocsStatus == nil {
+		ocsStatus = new(int)
+		ctx = response.RecordStatus(ctx, ocsStatus)
+	}
+	return r.WithContext(ctx), rctx, ocsStatus
+}
+
+// routePattern returns the pattern of the matched route, requests that did not match a route share one label
+func routePattern(rctx *chi.Context) string {
+	if p := rctx.RoutePattern(); p != "" {
+		return p
+	}
+	return "unmatched"
+}
+
+// responseFormat returns json or xml for ocs responses
+func responseFormat(h http.Header) string {
+	ct := h.Get("Content-Type")
+	switch {
+	case strings.Contains(ct, "json"):
+		return "json"
+	case strings.Contains(ct, "xml"):
+		return "xml"
+	default:
+		return ""
+	}
+}
+
+// httpStatus returns the status of the response, handlers that only write a body respond with 200
+func httpStatus(ww middleware.WrapResponseWriter) int {
+	if ww.Status() == 0 {
+		return http.StatusOK
+	}
+	return ww.Status()
+}
+
+// ocsStatusLabel returns the ocs status code, responses without an ocs payload have an empty label
+func ocsStatusLabel(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(code)
+}
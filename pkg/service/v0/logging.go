@@ -1,30 +1,162 @@
 package svc
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/owncloud/ocis-ocs/pkg/config"
+	ocsm "github.com/owncloud/ocis-ocs/pkg/middleware"
 	"github.com/owncloud/ocis-pkg/v2/log"
 )
 
-// NewLogging returns a service that logs messages.
-func NewLogging(next Service, logger log.Logger) Service {
-	return logging{
-		next:   next,
-		logger: logger,
+const (
+	// requestIDHeader is read by the request id middleware, setting it shares the id with the access log
+	requestIDHeader = "X-Request-Id"
+	// maxLoggedBody limits the size of form bodies read for the access log
+	maxLoggedBody = 64 << 10
+	redacted      = "***redacted***"
+)
+
+// NewLogging returns a service that writes an access log entry for every request.
+func NewLogging(next Service, logger log.Logger, cfg config.Log) Service {
+	l := logging{
+		next: next,
+		access: accessLogger{
+			logger: logger.Logger,
+			level:  accessLevel(logger, cfg.Access.Level, zerolog.InfoLevel),
+		},
+		routes: make(map[string]accessLogger, len(cfg.Access.Routes)),
 	}
+
+	for pattern, route := range cfg.Access.Routes {
+		a := accessLogger{
+			logger: logger.Logger,
+			level:  accessLevel(logger, route.Level, l.access.level),
+		}
+		if route.Sample > 1 {
+			a.logger = a.logger.Sample(&zerolog.BasicSampler{N: route.Sample})
+		}
+		l.routes[pattern] = a
+	}
+
+	return l
 }
 
 type logging struct {
 	next   Service
-	logger log.Logger
+	access accessLogger
+	// routes holds the access loggers of routes with their own level or sampling
+	routes map[string]accessLogger
+}
+
+// accessLogger writes access log entries with a fixed level
+type accessLogger struct {
+	logger zerolog.Logger
+	level  zerolog.Level
 }
 
 // ServeHTTP implements the Service interface.
 func (l logging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	l.next.ServeHTTP(w, r)
+	start := time.Now()
+	if r.Header.Get(requestIDHeader) == "" {
+		r.Header.Set(requestIDHeader, uuid.New().String())
+	}
+	params := requestParams(r)
+
+	var userid string
+	r, rctx, ocsStatus := observe(r)
+	r = r.WithContext(ocsm.RecordUser(r.Context(), &userid))
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+	l.next.ServeHTTP(ww, r)
+
+	route := routePattern(rctx)
+	a, ok := l.routes[route]
+	if !ok {
+		a = l.access
+	}
+	a.logger.WithLevel(a.level).
+		Str("request_id", r.Header.Get(requestIDHeader)).
+		Str("userid", userid).
+		Str("method", r.Method).
+		Str("route", route).
+		Str("params", params.Encode()).
+		Str("version", ww.Header().Get("Ocs-Api-Version")).
+		Str("format", responseFormat(ww.Header())).
+		Int("status", httpStatus(ww)).
+		Str("ocs_status", ocsStatusLabel(*ocsStatus)).
+		Dur("duration", time.Since(start)).
+		Int("bytes", ww.BytesWritten()).
+		Msg("access")
 }
 
 // GetConfig implements the Service interface.
 func (l logging) GetConfig(w http.ResponseWriter, r *http.Request) {
 	l.next.GetConfig(w, r)
 }
+
+// accessLevel parses a configured access log level, falling back to the default if it is empty or invalid
+func accessLevel(logger log.Logger, level string, fallback zerolog.Level) zerolog.Level {
+	if level == "" {
+		return fallback
+	}
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		logger.Warn().Err(err).Str("level", level).Msg("invalid access log level, using the default")
+		return fallback
+	}
+	return lvl
+}
+
+// requestParams returns the query and form body parameters of the request with passwords and tokens redacted.
+// Only form encoded bodies are read, the body is restored for the handlers.
+func requestParams(r *http.Request) url.Values {
+	params := url.Values{}
+	for k, v := range r.URL.Query() {
+		params[k] = append(params[k], v...)
+	}
+
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "application/x-www-form-urlencoded" && r.Body != nil {
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxLoggedBody))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+		// truncated bodies are not logged
+		if err == nil && len(b) < maxLoggedBody {
+			if body, err := url.ParseQuery(string(b)); err == nil {
+				for k, v := range body {
+					params[k] = append(params[k], v...)
+				}
+			}
+		}
+	}
+
+	for k := range params {
+		if sensitive(k) {
+			params[k] = []string{redacted}
+		}
+	}
+	// the edit user endpoints send the new password as value of the password key
+	if sensitive(params.Get("key")) && params.Get("value") != "" {
+		params["value"] = []string{redacted}
+	}
+	return params
+}
+
+// sensitive returns true for parameters containing passwords, tokens or secrets
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "password") || strings.Contains(name, "token") || strings.Contains(name, "secret")
+}
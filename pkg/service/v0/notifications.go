@@ -0,0 +1,175 @@
+package svc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cs3org/reva/pkg/user"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	merrors "github.com/micro/go-micro/v2/errors"
+
+	"github.com/owncloud/ocis-ocs/pkg/notifications"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+// notificationEndpoints are advertised in the notifications capabilities, they have to match the routes below
+var notificationEndpoints = []string{"list", "get", "delete", "delete-all", "admin-notifications"}
+
+// ListNotifications lists the notifications of the current user, newest first
+func (o Ocs) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := notificationUser(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := o.getNotificationManager().List(r.Context(), userID)
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", userID).Msg("could not list notifications")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list notifications"))
+		return
+	}
+
+	nd := make([]*data.Notification, 0, len(list))
+	for i := range list {
+		nd = append(nd, notificationData(list[i]))
+	}
+	render.Render(w, r, response.DataRender(nd))
+}
+
+// GetNotification returns a single notification of the current user
+func (o Ocs) GetNotification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := notificationUser(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "notificationid")
+
+	n, err := o.getNotificationManager().Get(r.Context(), userID, id)
+	switch {
+	case err == notifications.ErrNotFound:
+		render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "notification not found"))
+		return
+	case err != nil:
+		o.logger.Error().Err(err).Str("userid", userID).Str("notificationid", id).Msg("could not get notification")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get notification"))
+		return
+	}
+
+	render.Render(w, r, response.DataRender(notificationData(n)))
+}
+
+// DeleteNotification deletes a single notification of the current user
+func (o Ocs) DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := notificationUser(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "notificationid")
+
+	err := o.getNotificationManager().Delete(r.Context(), userID, id)
+	switch {
+	case err == notifications.ErrNotFound:
+		render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "notification not found"))
+		return
+	case err != nil:
+		o.logger.Error().Err(err).Str("userid", userID).Str("notificationid", id).Msg("could not delete notification")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not delete notification"))
+		return
+	}
+
+	o.logger.Debug().Str("userid", userID).Str("notificationid", id).Msg("deleted notification")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
+// DeleteAllNotifications deletes all notifications of the current user
+func (o Ocs) DeleteAllNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := notificationUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := o.getNotificationManager().DeleteAll(r.Context(), userID); err != nil {
+		o.logger.Error().Err(err).Str("userid", userID).Msg("could not delete notifications")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not delete notifications"))
+		return
+	}
+
+	o.logger.Debug().Str("userid", userID).Msg("deleted all notifications")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
+// PublishNotification creates a notification for a user. It lets other extensions publish notifications over http,
+// only admins are allowed to call it.
+func (o Ocs) PublishNotification(w http.ResponseWriter, r *http.Request) {
+	userid := chi.URLParam(r, "userid")
+
+	subject := r.PostFormValue("subject")
+	if subject == "" {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "please specify a subject"))
+		return
+	}
+	app := r.PostFormValue("app")
+	if app == "" {
+		app = "admin_notifications"
+	}
+
+	account, err := o.lookupAccount(r.Context(), userid)
+	if err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested user could not be found"))
+			return
+		}
+		o.logger.Error().Err(err).Str("userid", userid).Msg("could not get account")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get account"))
+		return
+	}
+
+	n, err := o.getNotificationManager().Publish(r.Context(), &notifications.Notification{
+		App:        app,
+		User:       account.Id,
+		ObjectType: r.PostFormValue("object_type"),
+		ObjectID:   r.PostFormValue("object_id"),
+		Subject:    subject,
+		Message:    r.PostFormValue("message"),
+		Link:       r.PostFormValue("link"),
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", account.Id).Msg("could not publish notification")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not publish notification"))
+		return
+	}
+
+	o.logger.Debug().Str("userid", account.Id).Str("notificationid", n.ID).Msg("published notification")
+	render.Render(w, r, response.DataRender(notificationData(n)))
+}
+
+func (o Ocs) getNotificationManager() *notifications.Manager {
+	return notifications.NewManager(o.getStoreService())
+}
+
+// notificationUser returns the id of the current user. If there is none an ocs error is rendered and false is returned.
+func notificationUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	u, ok := user.ContextGetUser(r.Context())
+	if !ok || u.GetId().GetOpaqueId() == "" {
+		render.Render(w, r, response.ErrRender(data.MetaUnauthorized.StatusCode, "missing user in context"))
+		return "", false
+	}
+	return u.Id.OpaqueId, true
+}
+
+func notificationData(n *notifications.Notification) *data.Notification {
+	return &data.Notification{
+		NotificationID: n.ID,
+		App:            n.App,
+		User:           n.User,
+		Datetime:       n.Datetime.UTC().Format(time.RFC3339),
+		ObjectType:     n.ObjectType,
+		ObjectID:       n.ObjectID,
+		Subject:        n.Subject,
+		Message:        n.Message,
+		Link:           n.Link,
+		Actions:        []interface{}{},
+	}
+}
@@ -3,8 +3,12 @@ package svc
 import (
 	"net/http"
 
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	"github.com/owncloud/ocis-ocs/pkg/config"
+	"github.com/owncloud/ocis-ocs/pkg/metrics"
 	"github.com/owncloud/ocis-pkg/v2/log"
+	settings "github.com/owncloud/ocis-settings/pkg/proto/v0"
+	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
 )
 
 // Option defines a single option function.
@@ -15,6 +19,14 @@ type Options struct {
 	Logger     log.Logger
 	Config     *config.Config
 	Middleware []func(http.Handler) http.Handler
+	// Metrics records the calls to backend services, if set
+	Metrics *metrics.Metrics
+	// GatewayClient replaces the reva gateway client, mostly useful for tests
+	GatewayClient gateway.GatewayAPIClient
+	// StoreService replaces the ocis-store client, mostly useful for tests
+	StoreService storepb.StoreService
+	// RoleService replaces the ocis-settings role service client, mostly useful for tests
+	RoleService settings.RoleService
 }
 
 // newOptions initializes the available default options.
@@ -48,3 +60,31 @@ func Middleware(val ...func(http.Handler) http.Handler) Option {
 		o.Middleware = val
 	}
 }
+
+// Metrics provides a function to set the metrics option.
+func Metrics(val *metrics.Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = val
+	}
+}
+
+// GatewayClient provides a function to set the gateway client option.
+func GatewayClient(val gateway.GatewayAPIClient) Option {
+	return func(o *Options) {
+		o.GatewayClient = val
+	}
+}
+
+// StoreService provides a function to set the store service option.
+func StoreService(val storepb.StoreService) Option {
+	return func(o *Options) {
+		o.StoreService = val
+	}
+}
+
+// RoleService provides a function to set the role service option.
+func RoleService(val settings.RoleService) Option {
+	return func(o *Options) {
+		o.RoleService = val
+	}
+}
@@ -0,0 +1,72 @@
+package svc
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/render"
+
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+// pagination holds the oc10 limit and offset query parameters of a listing. A negative limit returns all items.
+type pagination struct {
+	limit  int
+	offset int
+}
+
+// paginationParams reads the limit and offset query parameters.
+// If they are invalid an ocs error is rendered and false is returned.
+func paginationParams(w http.ResponseWriter, r *http.Request) (pagination, bool) {
+	p := pagination{limit: -1}
+	q := r.URL.Query()
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "invalid limit"))
+			return p, false
+		}
+		p.limit = limit
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "invalid offset"))
+			return p, false
+		}
+		p.offset = offset
+	}
+	return p, true
+}
+
+// page sorts the ids, so that pages are stable across requests, and returns the requested page
+func (p pagination) page(ids []string) []string {
+	sort.Strings(ids)
+	start, end := p.bounds(len(ids))
+	return ids[start:end]
+}
+
+// bounds returns the start and end index of the requested page in a sorted list of n items
+func (p pagination) bounds(n int) (int, int) {
+	if p.offset >= n {
+		return n, n
+	}
+	end := n
+	// compare with the remaining items, offset plus limit can overflow
+	if p.limit >= 0 && p.limit < n-p.offset {
+		end = p.offset + p.limit
+	}
+	return p.offset, end
+}
+
+// render renders a page of the items with the pagination meta data
+func (p pagination) render(w http.ResponseWriter, r *http.Request, d interface{}, total int) {
+	perPage := total
+	if p.limit >= 0 {
+		perPage = p.limit
+	}
+	render.Render(w, r, response.PaginatedDataRender(d, total, perPage))
+}
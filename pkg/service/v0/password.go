@@ -0,0 +1,119 @@
+package svc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/owncloud/ocis-ocs/pkg/config"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+)
+
+// passwordPolicy checks passwords set through the provisioning API against the configured rules
+type passwordPolicy struct {
+	config.PasswordPolicy
+	// disallowed holds the lower cased passwords of the disallowed file
+	disallowed map[string]struct{}
+}
+
+// newPasswordPolicy loads the disallowed passwords of the policy. Empty lines and lines starting with # are ignored.
+func newPasswordPolicy(cfg config.PasswordPolicy) (*passwordPolicy, error) {
+	p := &passwordPolicy{
+		PasswordPolicy: cfg,
+		disallowed:     map[string]struct{}{},
+	}
+	if cfg.DisallowedFile == "" {
+		return p, nil
+	}
+
+	f, err := os.Open(cfg.DisallowedFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.disallowed[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read disallowed passwords from %s: %w", cfg.DisallowedFile, err)
+	}
+	return p, nil
+}
+
+// violation returns a message for the user if the password violates the policy, an empty string otherwise
+func (p *passwordPolicy) violation(password, username string) string {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Sprintf("The password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Sprintf("The password must be at most %d characters long", p.MaxLength)
+	}
+
+	var lower, upper, digits, special int
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower++
+		case unicode.IsUpper(c):
+			upper++
+		case unicode.IsDigit(c):
+			digits++
+		case !unicode.IsLetter(c):
+			special++
+		}
+	}
+	if lower < p.MinLowerCase {
+		return characterClassViolation(p.MinLowerCase, "lowercase letter")
+	}
+	if upper < p.MinUpperCase {
+		return characterClassViolation(p.MinUpperCase, "uppercase letter")
+	}
+	if digits < p.MinDigits {
+		return characterClassViolation(p.MinDigits, "digit")
+	}
+	if special < p.MinSpecial {
+		return characterClassViolation(p.MinSpecial, "special character")
+	}
+
+	if p.DisallowUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return "The password must not contain the username"
+	}
+	if _, ok := p.disallowed[strings.ToLower(password)]; ok {
+		return "The password is too common, choose a different one"
+	}
+	return ""
+}
+
+func characterClassViolation(n int, class string) string {
+	if n != 1 {
+		class += "s"
+	}
+	return fmt.Sprintf("The password must contain at least %d %s", n, class)
+}
+
+// capability advertises the policy, so that clients can validate passwords up front
+func (p *passwordPolicy) capability() *data.CapabilitiesPasswordPolicy {
+	c := &data.CapabilitiesPasswordPolicy{
+		MinCharacters:        p.MinLength,
+		MaxCharacters:        p.MaxLength,
+		MinLowerCase:         p.MinLowerCase,
+		MinUpperCase:         p.MinUpperCase,
+		MinDigits:            p.MinDigits,
+		MinSpecialCharacters: p.MinSpecial,
+		DisallowedPasswords:  len(p.disallowed) > 0,
+	}
+	if p.DisallowUsername {
+		c.DisallowUsername = true
+	}
+	return c
+}
@@ -0,0 +1,490 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/go-chi/render"
+
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+// expirationFormat is the oc10 format used to render expiration dates
+const expirationFormat = "2006-01-02 15:04:05"
+
+// dateLayout is the format of expireDate parameters without a time
+const dateLayout = "2006-01-02"
+
+// expirationLayouts are the accepted formats for the expireDate parameter
+var expirationLayouts = []string{dateLayout, expirationFormat, time.RFC3339}
+
+// createPublicShare creates a public link
+func (o Ocs) createPublicShare(w http.ResponseWriter, r *http.Request) {
+	if !o.capabilities.FilesSharing.Public.Enabled {
+		render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "public link sharing is disabled"))
+		return
+	}
+
+	p := r.PostFormValue("path")
+	if p == "" {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "please specify a file or folder path"))
+		return
+	}
+
+	gwc, err := o.getGatewayClient()
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not get gateway client")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get gateway client"))
+		return
+	}
+
+	info, ok := o.statPath(w, r, gwc, p)
+	if !ok {
+		return
+	}
+
+	permissions := data.PermissionRead
+	if r.PostFormValue("permissions") != "" || r.PostFormValue("publicUpload") != "" {
+		if permissions, err = o.linkPermissions(r, info); err != nil {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, err.Error()))
+			return
+		}
+	}
+
+	password := r.PostFormValue("password")
+	if password == "" && o.passwordRequired(permissions) {
+		render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "a password is required for this kind of link"))
+		return
+	}
+
+	expiration, err := o.linkExpiration(r.PostFormValue("expireDate"), true)
+	if err != nil {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, err.Error()))
+		return
+	}
+
+	res, err := gwc.CreatePublicShare(r.Context(), &link.CreatePublicShareRequest{
+		ResourceInfo: info,
+		Grant: &link.Grant{
+			Permissions: &link.PublicSharePermissions{
+				Permissions: cs3Permissions(permissions),
+			},
+			Password:   password,
+			Expiration: expiration,
+		},
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("path", p).Msg("could not create public link")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not create public link"))
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("path", p).Msg("could not create public link")
+		renderRPCStatus(w, r, res.Status, "could not create public link")
+		return
+	}
+	share := res.Share
+
+	// the display name is not part of the grant, it has to be set in a separate update
+	if name := r.PostFormValue("name"); name != "" {
+		update := &link.UpdatePublicShareRequest_Update{
+			Type:        link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME,
+			DisplayName: name,
+		}
+		named, ok := o.sendPublicShareUpdate(w, r, gwc, share.GetId().GetOpaqueId(), update)
+		if !ok {
+			// the link would be left behind without the requested name
+			o.discardPublicShare(r.Context(), gwc, share.GetId().GetOpaqueId())
+			return
+		}
+		share = named
+	}
+
+	sd, err := o.publicShareData(r.Context(), gwc, share, info)
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", share.GetId().GetOpaqueId()).Msg("could not convert public link")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not convert share"))
+		return
+	}
+
+	o.logger.Debug().Str("shareid", sd.ID).Str("path", p).Msg("created public link")
+	render.Render(w, r, response.DataRender(sd))
+}
+
+// updatePublicShare changes the permissions, password, expiration or name of a public link.
+// All parameters are validated before the first update is sent. The gateway only accepts one change per update,
+// so if an update fails the ones already applied are reverted. The password is sent last, because it cannot be
+// reverted.
+func (o Ocs) updatePublicShare(w http.ResponseWriter, r *http.Request, gwc gateway.GatewayAPIClient, share *link.PublicShare) {
+	shareID := share.GetId().GetOpaqueId()
+	// the fields that are restored if an update fails
+	original := &link.PublicShare{
+		Id:          share.GetId(),
+		Permissions: share.GetPermissions(),
+		Expiration:  share.GetExpiration(),
+		DisplayName: share.GetDisplayName(),
+	}
+
+	updates := []*link.UpdatePublicShareRequest_Update{}
+
+	if v, ok := r.PostForm["expireDate"]; ok {
+		expiration, err := o.linkExpiration(v[0], false)
+		if err != nil {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, err.Error()))
+			return
+		}
+		updates = append(updates, &link.UpdatePublicShareRequest_Update{
+			Type: link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION,
+			Grant: &link.Grant{
+				Expiration: expiration,
+			},
+		})
+	}
+
+	if v, ok := r.PostForm["name"]; ok {
+		updates = append(updates, &link.UpdatePublicShareRequest_Update{
+			Type:        link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME,
+			DisplayName: v[0],
+		})
+	}
+
+	info, err := o.statID(r.Context(), gwc, share.GetResourceId())
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not stat shared resource")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not stat shared resource"))
+		return
+	}
+
+	permissions := ocsPermissions(share.GetPermissions().GetPermissions())
+	_, hasPermissions := r.PostForm["permissions"]
+	_, hasPublicUpload := r.PostForm["publicUpload"]
+	if hasPermissions || hasPublicUpload {
+		if permissions, err = o.linkPermissions(r, info); err != nil {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, err.Error()))
+			return
+		}
+		updates = append(updates, &link.UpdatePublicShareRequest_Update{
+			Type: link.UpdatePublicShareRequest_Update_TYPE_PERMISSIONS,
+			Grant: &link.Grant{
+				Permissions: &link.PublicSharePermissions{
+					Permissions: cs3Permissions(permissions),
+				},
+			},
+		})
+	}
+
+	hasPassword := share.GetPasswordProtected()
+	if v, ok := r.PostForm["password"]; ok {
+		// an empty password removes the password protection
+		hasPassword = v[0] != ""
+		updates = append(updates, &link.UpdatePublicShareRequest_Update{
+			Type: link.UpdatePublicShareRequest_Update_TYPE_PASSWORD,
+			Grant: &link.Grant{
+				Password: v[0],
+			},
+		})
+	}
+
+	if len(updates) == 0 {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "wrong or no update parameter given"))
+		return
+	}
+
+	if !hasPassword && o.passwordRequired(permissions) {
+		render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "a password is required for this kind of link"))
+		return
+	}
+
+	for i, update := range updates {
+		updated, ok := o.sendPublicShareUpdate(w, r, gwc, shareID, update)
+		if !ok {
+			o.revertPublicShareUpdates(r.Context(), gwc, original, updates[:i])
+			return
+		}
+		share = updated
+	}
+
+	sd, err := o.publicShareData(r.Context(), gwc, share, info)
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not convert public link")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not convert share"))
+		return
+	}
+
+	o.logger.Debug().Str("shareid", shareID).Int("updates", len(updates)).Msg("updated public link")
+	render.Render(w, r, response.DataRender(sd))
+}
+
+// revertPublicShareUpdates restores the permissions, expiration and name of the original link that were changed by
+// the applied updates. Failures are only logged, the error of the failed update has already been rendered.
+func (o Ocs) revertPublicShareUpdates(ctx context.Context, gwc gateway.GatewayAPIClient, original *link.PublicShare, applied []*link.UpdatePublicShareRequest_Update) {
+	shareID := original.GetId().GetOpaqueId()
+	for _, update := range applied {
+		revert := &link.UpdatePublicShareRequest_Update{Type: update.Type}
+		switch update.Type {
+		case link.UpdatePublicShareRequest_Update_TYPE_PERMISSIONS:
+			revert.Grant = &link.Grant{Permissions: original.GetPermissions()}
+		case link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION:
+			revert.Grant = &link.Grant{Expiration: original.GetExpiration()}
+		case link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME:
+			revert.DisplayName = original.GetDisplayName()
+		default:
+			o.logger.Error().Str("shareid", shareID).Str("type", update.Type.String()).Msg("could not revert public link update")
+			continue
+		}
+		res, err := gwc.UpdatePublicShare(ctx, &link.UpdatePublicShareRequest{
+			Ref:    publicShareRef(shareID),
+			Update: revert,
+		})
+		if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+			o.logger.Error().Err(err).Str("shareid", shareID).Str("type", update.Type.String()).Msg("could not revert public link update")
+		}
+	}
+}
+
+// discardPublicShare removes a public link that could not be completed. Failures are only logged.
+func (o Ocs) discardPublicShare(ctx context.Context, gwc gateway.GatewayAPIClient, shareID string) {
+	res, err := gwc.RemovePublicShare(ctx, &link.RemovePublicShareRequest{
+		Ref: publicShareRef(shareID),
+	})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not remove incomplete public link")
+	}
+}
+
+// sendPublicShareUpdate sends a single update for a public link to the gateway.
+// On failure an ocs error is rendered and false is returned.
+func (o Ocs) sendPublicShareUpdate(w http.ResponseWriter, r *http.Request, gwc gateway.GatewayAPIClient, shareID string, update *link.UpdatePublicShareRequest_Update) (*link.PublicShare, bool) {
+	res, err := gwc.UpdatePublicShare(r.Context(), &link.UpdatePublicShareRequest{
+		Ref:    publicShareRef(shareID),
+		Update: update,
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Str("type", update.Type.String()).Msg("could not update public link")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not update public link"))
+		return nil, false
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("shareid", shareID).Str("type", update.Type.String()).Msg("could not update public link")
+		renderRPCStatus(w, r, res.Status, "could not update public link")
+		return nil, false
+	}
+	return res.Share, true
+}
+
+// removePublicShare deletes a public link
+func (o Ocs) removePublicShare(w http.ResponseWriter, r *http.Request, gwc gateway.GatewayAPIClient, shareID string) {
+	res, err := gwc.RemovePublicShare(r.Context(), &link.RemovePublicShareRequest{
+		Ref: publicShareRef(shareID),
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not remove public link")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not remove public link"))
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("shareid", shareID).Msg("could not remove public link")
+		renderRPCStatus(w, r, res.Status, "share not found")
+		return
+	}
+
+	o.logger.Debug().Str("shareid", shareID).Msg("removed public link")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
+// listPublicShares lists the public links of the current user, filtered by resource if the info is not nil.
+// On failure an ocs error is rendered and false is returned.
+func (o Ocs) listPublicShares(w http.ResponseWriter, r *http.Request, gwc gateway.GatewayAPIClient, info *provider.ResourceInfo) ([]*data.ShareData, bool) {
+	filters := []*link.ListPublicSharesRequest_Filter{}
+	if info != nil {
+		filters = append(filters, &link.ListPublicSharesRequest_Filter{
+			Type: link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID,
+			Term: &link.ListPublicSharesRequest_Filter_ResourceId{
+				ResourceId: info.Id,
+			},
+		})
+	}
+
+	res, err := gwc.ListPublicShares(r.Context(), &link.ListPublicSharesRequest{
+		Filters: filters,
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not list public links")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list public links"))
+		return nil, false
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Msg("could not list public links")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list public links"))
+		return nil, false
+	}
+
+	shares := make([]*data.ShareData, 0, len(res.Share))
+	for i := range res.Share {
+		sd, err := o.publicShareData(r.Context(), gwc, res.Share[i], info)
+		if err != nil {
+			o.logger.Error().Err(err).Str("shareid", res.Share[i].GetId().GetOpaqueId()).Msg("could not convert public link, skipping")
+			continue
+		}
+		shares = append(shares, sd)
+	}
+	return shares, true
+}
+
+// getPublicShare looks up a public link by id, returning false if there is none
+func (o Ocs) getPublicShare(ctx context.Context, gwc gateway.GatewayAPIClient, shareID string) (*link.PublicShare, bool) {
+	res, err := gwc.GetPublicShare(ctx, &link.GetPublicShareRequest{
+		Ref: publicShareRef(shareID),
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not get public link")
+		return nil, false
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		if res.Status.Code != rpc.Code_CODE_NOT_FOUND {
+			o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("shareid", shareID).Msg("could not get public link")
+		}
+		return nil, false
+	}
+	return res.Share, true
+}
+
+// publicShareData converts a cs3 public share into the oc10 share format. If the resource info is nil it is looked up.
+func (o Ocs) publicShareData(ctx context.Context, gwc gateway.GatewayAPIClient, s *link.PublicShare, info *provider.ResourceInfo) (*data.ShareData, error) {
+	sd := &data.ShareData{
+		ID:           s.GetId().GetOpaqueId(),
+		ShareType:    data.ShareTypePublicLink,
+		Permissions:  ocsPermissions(s.GetPermissions().GetPermissions()),
+		STime:        s.GetCtime().GetSeconds(),
+		UIDOwner:     s.GetCreator().GetOpaqueId(),
+		UIDFileOwner: s.GetOwner().GetOpaqueId(),
+		Token:        s.GetToken(),
+		Name:         s.GetDisplayName(),
+		URL:          strings.TrimSuffix(o.config.Sharing.PublicURL, "/") + "/#/s/" + s.GetToken(),
+	}
+	if s.GetPasswordProtected() {
+		// oc10 returns the password hash, which must never leave the storage
+		sd.ShareWith = "***redacted***"
+		sd.ShareWithDisplayname = "***redacted***"
+	}
+	if s.GetExpiration() != nil {
+		sd.Expiration = time.Unix(int64(s.GetExpiration().GetSeconds()), 0).UTC().Format(expirationFormat)
+	}
+
+	if err := o.completeShareData(ctx, gwc, sd, s.GetResourceId(), info); err != nil {
+		return nil, err
+	}
+	return sd, nil
+}
+
+// linkPermissions determines the permissions of a public link from the permissions or the legacy publicUpload parameter
+func (o Ocs) linkPermissions(r *http.Request, info *provider.ResourceInfo) (int, error) {
+	permissions := data.PermissionRead
+	if v := r.PostFormValue("permissions"); v != "" {
+		var err error
+		if permissions, err = parsePermissions(v); err != nil {
+			return 0, err
+		}
+	} else if r.PostFormValue("publicUpload") == "true" {
+		permissions = data.PermissionRead | data.PermissionUpdate | data.PermissionCreate | data.PermissionDelete
+	}
+
+	switch permissions {
+	case data.PermissionRead:
+		return permissions, nil
+	case data.PermissionCreate, data.PermissionRead | data.PermissionCreate, data.PermissionRead | data.PermissionUpdate | data.PermissionCreate | data.PermissionDelete:
+		// supported upload permissions, checked below
+	default:
+		return 0, fmt.Errorf("public links only support read only, upload only and read/write permissions")
+	}
+
+	if info.GetType() != provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+		return 0, fmt.Errorf("public upload is only possible for public shared folders")
+	}
+	if !o.capabilities.FilesSharing.Public.Upload {
+		return 0, fmt.Errorf("public upload is disabled")
+	}
+	if permissions == data.PermissionCreate && !o.capabilities.FilesSharing.Public.SupportsUploadOnly {
+		return 0, fmt.Errorf("upload only links are disabled")
+	}
+	return permissions, nil
+}
+
+// passwordRequired checks if a password is enforced for public links with the given permissions
+func (o Ocs) passwordRequired(permissions int) bool {
+	password := o.capabilities.FilesSharing.Public.Password
+	switch {
+	case bool(password.Enforced):
+		return true
+	case permissions == data.PermissionCreate:
+		return bool(password.EnforcedFor.UploadOnly)
+	case permissions&(data.PermissionUpdate|data.PermissionCreate|data.PermissionDelete) != 0:
+		return bool(password.EnforcedFor.ReadWrite)
+	default:
+		return bool(password.EnforcedFor.ReadOnly)
+	}
+}
+
+// linkExpiration parses the expireDate parameter and checks it against the expiration capabilities.
+// An empty value removes the expiration, unless it is enforced. For new links the default expiration is applied.
+func (o Ocs) linkExpiration(v string, create bool) (*types.Timestamp, error) {
+	expireDate := o.capabilities.FilesSharing.Public.ExpireDate
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	maxDays := time.Duration(expireDate.Days) * 24 * time.Hour
+
+	if v == "" {
+		switch {
+		case create && bool(expireDate.Enabled || expireDate.Enforced) && expireDate.Days > 0:
+			return &types.Timestamp{Seconds: uint64(endOfDay(today.Add(maxDays)).Unix())}, nil
+		case bool(expireDate.Enforced):
+			return nil, fmt.Errorf("an expiration date is required")
+		default:
+			return nil, nil
+		}
+	}
+
+	var t time.Time
+	var err error
+	for _, layout := range expirationLayouts {
+		if t, err = time.Parse(layout, v); err == nil {
+			if layout == dateLayout {
+				// like oc10 links expire at the end of the given day
+				t = endOfDay(t)
+			}
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid date, date format must be YYYY-MM-DD")
+	}
+
+	if !t.After(time.Now()) {
+		return nil, fmt.Errorf("expiration date is in the past")
+	}
+	if bool(expireDate.Enforced) && expireDate.Days > 0 && t.After(endOfDay(today.Add(maxDays))) {
+		return nil, fmt.Errorf("cannot set expiration date more than %d days in the future", expireDate.Days)
+	}
+	return &types.Timestamp{Seconds: uint64(t.Unix())}, nil
+}
+
+// endOfDay returns the last second of the day of t
+func endOfDay(t time.Time) time.Time {
+	return t.Truncate(24 * time.Hour).Add(24*time.Hour - time.Second)
+}
+
+func publicShareRef(id string) *link.PublicShareReference {
+	return &link.PublicShareReference{
+		Spec: &link.PublicShareReference_Id{
+			Id: &link.PublicShareId{OpaqueId: id},
+		},
+	}
+}
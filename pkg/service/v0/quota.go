@@ -0,0 +1,334 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/token"
+	"github.com/cs3org/reva/pkg/token/manager/jwt"
+	merrors "github.com/micro/go-micro/v2/errors"
+	"google.golang.org/grpc/metadata"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
+)
+
+const (
+	// defaultQuotaDefinition is reported when no quota has been configured for a user
+	defaultQuotaDefinition = "default"
+	// unlimitedQuotaDefinition removes the quota limit
+	unlimitedQuotaDefinition = "none"
+	// unlimitedQuota is the number of bytes used for unlimited quotas
+	unlimitedQuota int64 = -1
+
+	quotaDatabase = "ocs"
+	quotaTable    = "quotas"
+	// quotaMetadataKey is the arbitrary metadata key used to hand the quota limit of a user to the storage.
+	// The CS3 API has no call to set a quota and the reva storage drivers do not read this key yet.
+	quotaMetadataKey = "quota"
+)
+
+// quotaPattern matches oc10 style quota values like "5 GB", "1.5TB" or "1024"
+var quotaPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([kmgtp]?)b?$`)
+
+var quotaUnits = map[string]float64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
+}
+
+// getQuota looks up the quota of the users home storage. The user data is still useful without the quota,
+// so errors are only logged and a quota without numbers is returned.
+func (o Ocs) getQuota(ctx context.Context, account *accounts.Account) *data.Quota {
+	definition, err := o.readQuotaDefinition(ctx, account.Id)
+	if err != nil {
+		o.logger.Warn().Err(err).Str("userid", account.Id).Msg("could not read quota definition, using the default")
+		definition = defaultQuotaDefinition
+	}
+	q := &data.Quota{
+		Definition: definition,
+	}
+
+	limit, err := o.effectiveQuota(account, definition)
+	if err != nil {
+		o.logger.Warn().Err(err).Str("userid", account.Id).Str("definition", definition).Msg("invalid quota definition, ignoring it")
+		limit = unlimitedQuota
+	}
+
+	gwc, err := o.getGatewayClient()
+	if err != nil {
+		o.logger.Warn().Err(err).Str("userid", account.Id).Msg("could not get gateway client, omitting quota")
+		return q
+	}
+
+	ctx, err = o.userContext(ctx, account)
+	if err != nil {
+		o.logger.Warn().Err(err).Str("userid", account.Id).Msg("could not act on behalf of the user, omitting quota")
+		return q
+	}
+
+	res, err := gwc.GetQuota(ctx, &gateway.GetQuotaRequest{
+		Ref: o.homeRef(),
+	})
+	if err != nil {
+		o.logger.Warn().Err(err).Str("userid", account.Id).Msg("could not get quota, omitting it")
+		return q
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Warn().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("userid", account.Id).Msg("could not get quota, omitting it")
+		return q
+	}
+
+	used := int64(res.UsedBytes)
+	total := int64(res.TotalBytes)
+	// the configured limit applies when the storage is larger or does not report a size
+	if limit != unlimitedQuota && (total == 0 || limit < total) {
+		total = limit
+	}
+	q.Used = used
+	q.Total = total
+	if total > used {
+		q.Free = total - used
+	}
+	if total > 0 {
+		// oc10 reports the used space in percent, rounded to two decimals
+		q.Relative = float32(math.Round(float64(used)/float64(total)*10000) / 100)
+	}
+	return q
+}
+
+// setQuota hands the limit resulting from the quota definition to the storage and persists the definition.
+// The storage is updated first. If the definition cannot be saved afterwards the previous limit is restored,
+// so a failure leaves the quota of the user unchanged.
+func (o Ocs) setQuota(ctx context.Context, account *accounts.Account, definition string) error {
+	limit, err := o.effectiveQuota(account, definition)
+	if err != nil {
+		return err
+	}
+	previous, err := o.readQuotaDefinition(ctx, account.Id)
+	if err != nil {
+		return err
+	}
+
+	if err := o.applyQuota(ctx, account, limit); err != nil {
+		return err
+	}
+
+	if err := o.saveQuotaDefinition(ctx, account.Id, definition); err != nil {
+		previousLimit, perr := o.effectiveQuota(account, previous)
+		if perr != nil {
+			// getQuota ignores invalid definitions as well
+			previousLimit = unlimitedQuota
+		}
+		if rerr := o.applyQuota(ctx, account, previousLimit); rerr != nil {
+			o.logger.Error().Err(rerr).Str("userid", account.Id).Str("quota", previous).Msg("could not restore the previous quota limit")
+		}
+		return err
+	}
+	return nil
+}
+
+// saveQuotaDefinition stores the quota definition of the user, the default definition is not stored
+func (o Ocs) saveQuotaDefinition(ctx context.Context, userid, definition string) error {
+	if definition == defaultQuotaDefinition {
+		_, err := o.getStoreService().Delete(ctx, &storepb.DeleteRequest{
+			Options: &storepb.DeleteOptions{
+				Database: quotaDatabase,
+				Table:    quotaTable,
+			},
+			Key: userid,
+		})
+		return err
+	}
+
+	_, err := o.getStoreService().Write(ctx, &storepb.WriteRequest{
+		Options: &storepb.WriteOptions{
+			Database: quotaDatabase,
+			Table:    quotaTable,
+		},
+		Record: &storepb.Record{
+			Key:   userid,
+			Value: []byte(definition),
+		},
+	})
+	return err
+}
+
+// applyQuota sets the limit as metadata on the home of the user. It is not enforced by the storage drivers
+// of reva yet, until then the limit is only reported by getQuota.
+func (o Ocs) applyQuota(ctx context.Context, account *accounts.Account, limit int64) error {
+	gwc, err := o.getGatewayClient()
+	if err != nil {
+		return err
+	}
+	ctx, err = o.userContext(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	value := unlimitedQuotaDefinition
+	if limit != unlimitedQuota {
+		value = strconv.FormatInt(limit, 10)
+	}
+
+	res, err := gwc.SetArbitraryMetadata(ctx, &provider.SetArbitraryMetadataRequest{
+		Ref: o.homeRef(),
+		ArbitraryMetadata: &provider.ArbitraryMetadata{
+			Metadata: map[string]string{quotaMetadataKey: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return fmt.Errorf("could not set quota metadata: %s %s", res.Status.Code.String(), res.Status.Message)
+	}
+	return nil
+}
+
+// readQuotaDefinition returns the quota definition of the user, "default" if none has been set
+func (o Ocs) readQuotaDefinition(ctx context.Context, userid string) (string, error) {
+	res, err := o.getStoreService().Read(ctx, &storepb.ReadRequest{
+		Options: &storepb.ReadOptions{
+			Database: quotaDatabase,
+			Table:    quotaTable,
+		},
+		Key: userid,
+	})
+	if err != nil {
+		if merrors.Parse(err.Error()).Code == http.StatusNotFound {
+			return defaultQuotaDefinition, nil
+		}
+		return "", err
+	}
+	if len(res.Records) == 0 {
+		return defaultQuotaDefinition, nil
+	}
+	return string(res.Records[0].Value), nil
+}
+
+// effectiveQuota resolves the quota definition of the account to a limit in bytes.
+// The default quota is the most generous default of the groups of the account, or the global default.
+func (o Ocs) effectiveQuota(account *accounts.Account, definition string) (int64, error) {
+	if definition != defaultQuotaDefinition {
+		return quotaBytes(definition)
+	}
+
+	found := false
+	limit := unlimitedQuota
+	for _, g := range account.MemberOf {
+		groupDefinition, ok := o.config.Quota.Groups[g.Id]
+		if !ok {
+			continue
+		}
+		groupLimit, err := quotaBytes(groupDefinition)
+		if err != nil {
+			return 0, fmt.Errorf("invalid default quota for group %s: %v", g.Id, err)
+		}
+		if groupLimit == unlimitedQuota {
+			return unlimitedQuota, nil
+		}
+		if !found || groupLimit > limit {
+			limit = groupLimit
+		}
+		found = true
+	}
+	if found {
+		return limit, nil
+	}
+
+	if o.config.Quota.Default == "" || o.config.Quota.Default == defaultQuotaDefinition {
+		return unlimitedQuota, nil
+	}
+	return quotaBytes(o.config.Quota.Default)
+}
+
+// parseQuota validates an oc10 quota value and returns the definition to store
+func parseQuota(value string) (string, error) {
+	definition := strings.TrimSpace(value)
+	switch strings.ToLower(definition) {
+	case "":
+		return "", fmt.Errorf("Invalid quota value %s", value)
+	case defaultQuotaDefinition, unlimitedQuotaDefinition:
+		return strings.ToLower(definition), nil
+	}
+	if _, err := quotaBytes(definition); err != nil {
+		return "", fmt.Errorf("Invalid quota value %s", value)
+	}
+	return definition, nil
+}
+
+// quotaBytes converts a quota definition into bytes, using binary units like oc10
+func quotaBytes(definition string) (int64, error) {
+	d := strings.ToLower(strings.TrimSpace(definition))
+	if d == unlimitedQuotaDefinition {
+		return unlimitedQuota, nil
+	}
+
+	m := quotaPattern.FindStringSubmatch(d)
+	if m == nil {
+		return 0, fmt.Errorf("invalid quota %s", definition)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	b := math.Round(n * quotaUnits[m[2]])
+	// float64(math.MaxInt64) is 2^63, which does not fit into an int64 anymore
+	if b >= math.MaxInt64 {
+		return 0, fmt.Errorf("quota %s is too large", definition)
+	}
+	return int64(b), nil
+}
+
+func (o Ocs) homeRef() *provider.Reference {
+	return &provider.Reference{
+		Spec: &provider.Reference_Path{Path: o.config.Reva.HomeNamespace},
+	}
+}
+
+// userContext returns a context to make calls to the reva gateway on behalf of the account.
+// The storage resolves the home of the user from the access token, so when the account is not the
+// current user a token is minted for it.
+func (o Ocs) userContext(ctx context.Context, account *accounts.Account) (context.Context, error) {
+	if u, ok := currentUser(ctx); ok && u.Id.OpaqueId == account.Id {
+		return ctx, nil
+	}
+
+	tokenManager, err := jwt.New(map[string]interface{}{
+		"secret":  o.config.TokenManager.JWTSecret,
+		"expires": int64(60),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := tokenManager.MintToken(ctx, &userpb.User{
+		Id: &userpb.UserId{
+			OpaqueId: account.Id,
+		},
+		Username:    account.OnPremisesSamAccountName,
+		Mail:        account.Mail,
+		DisplayName: account.DisplayName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = token.ContextSetToken(ctx, t)
+	// replace the token of the current user instead of appending to it
+	return metadata.NewOutgoingContext(ctx, metadata.Pairs(token.TokenHeader, t)), nil
+}
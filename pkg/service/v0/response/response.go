@@ -1,9 +1,11 @@
 package response
 
 import (
+	"context"
 	"encoding/xml"
 	"net/http"
 	"reflect"
+	"strconv"
 
 	"github.com/go-chi/render"
 	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
@@ -74,12 +76,27 @@ func (rsp *Response) Render(w http.ResponseWriter, r *http.Request) error {
 	m := statusCodeMapper(version)
 	statusCode := m(rsp.OCS.Meta)
 	render.Status(r, statusCode)
+	if code, ok := r.Context().Value(statusKey).(*int); ok {
+		*code = rsp.OCS.Meta.StatusCode
+	}
 	if version == ocsVersion2 && statusCode == http.StatusOK {
 		rsp.OCS.Meta.StatusCode = statusCode
 	}
 	return nil
 }
 
+// RecordStatus returns a context in which rendered responses store their ocs status code in code,
+// so that services wrapping the handlers can report it
+func RecordStatus(ctx context.Context, code *int) context.Context {
+	return context.WithValue(ctx, statusKey, code)
+}
+
+// StatusRecorder returns the pointer the ocs status code is recorded in, nil if the request is not recorded
+func StatusRecorder(ctx context.Context) *int {
+	code, _ := ctx.Value(statusKey).(*int)
+	return code
+}
+
 // DataRender creates an OK Payload for the given data
 func DataRender(d interface{}) render.Renderer {
 	return &Response{
@@ -90,6 +107,20 @@ func DataRender(d interface{}) render.Renderer {
 	}
 }
 
+// PaginatedDataRender creates an OK Payload for a page of data, the meta data contains the total number of
+// items and the page size
+func PaginatedDataRender(d interface{}, totalItems, itemsPerPage int) render.Renderer {
+	meta := data.MetaOK
+	meta.TotalItems = strconv.Itoa(totalItems)
+	meta.ItemsPerPage = strconv.Itoa(itemsPerPage)
+	return &Response{
+		&Payload{
+			Meta: meta,
+			Data: d,
+		},
+	}
+}
+
 // ErrRender creates an Error Paylod with the given OCS error code and message
 // The httpcode will be determined using the API version stored in the context
 func ErrRender(c int, m string) render.Renderer {
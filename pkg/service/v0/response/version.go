@@ -13,8 +13,12 @@ type key int
 
 const (
 	apiVersionKey key = iota
-	ocsVersion1       = "1"
-	ocsVersion2       = "2"
+	statusKey
+)
+
+const (
+	ocsVersion1 = "1"
+	ocsVersion2 = "2"
 )
 
 var (
@@ -3,9 +3,12 @@ package svc
 import (
 	"net/http"
 
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/render"
+	"github.com/micro/go-micro/v2/client"
 	"github.com/micro/go-micro/v2/client/grpc"
 
 	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
@@ -14,6 +17,8 @@ import (
 	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
 	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
 	"github.com/owncloud/ocis-pkg/v2/log"
+	settings "github.com/owncloud/ocis-settings/pkg/proto/v0"
+	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
 )
 
 var defaultClient = grpc.NewClient()
@@ -31,48 +36,111 @@ func NewService(opts ...Option) Service {
 	m := chi.NewMux()
 	m.Use(options.Middleware...)
 
+	passwords, err := newPasswordPolicy(options.Config.PasswordPolicy)
+	if err != nil {
+		options.Logger.Fatal().Err(err).Msg("could not load the password policy")
+	}
+	capabilities := newCapabilities(options.Config.Capabilities)
+	capabilities.PasswordPolicy = passwords.capability()
+
 	svc := Ocs{
-		config: options.Config,
-		mux:    m,
-		logger: options.Logger,
+		config:       options.Config,
+		mux:          m,
+		logger:       options.Logger,
+		capabilities: capabilities,
+		passwords:    passwords,
+		client:       newBackendClient(defaultClient, options.Metrics),
+		gateway:      options.GatewayClient,
+		store:        options.StoreService,
+		roles:        options.RoleService,
 	}
 
 	m.Route(options.Config.HTTP.Root, func(r chi.Router) {
 		r.NotFound(svc.NotFound)
 		r.Use(middleware.StripSlashes)
-		r.Use(ocsm.AccessToken(
-			ocsm.Logger(options.Logger),
-			ocsm.TokenManagerConfig(options.Config.TokenManager),
-		))
 		r.Use(ocsm.OCSFormatCtx) // updates request Accept header according to format=(json|xml) query parameter
 		r.Route("/v{version:(1|2)}.php", func(r chi.Router) {
 			r.Use(response.VersionCtx) // stores version in context
-			r.Route("/apps/files_sharing/api/v1", func(r chi.Router) {})
-			r.Route("/apps/notifications/api/v1", func(r chi.Router) {})
+			r.Use(ocsm.AccessToken(
+				ocsm.Logger(options.Logger),
+				ocsm.TokenManagerConfig(options.Config.TokenManager),
+				ocsm.AccountsService(svc.getAccountService()),
+			))
+			if options.Config.Authentication.BasicAuth {
+				r.Use(ocsm.BasicAuth(
+					ocsm.Logger(options.Logger),
+					ocsm.TokenManagerConfig(options.Config.TokenManager),
+					ocsm.VerifyPassword(svc.verifyPassword),
+				))
+			}
+			if options.Config.Authentication.OIDC.Issuer != "" {
+				r.Use(ocsm.BearerAuth(
+					ocsm.Logger(options.Logger),
+					ocsm.TokenManagerConfig(options.Config.TokenManager),
+					ocsm.AccountsService(svc.getAccountService()),
+					ocsm.OIDC(options.Config.Authentication.OIDC),
+				))
+			}
+			r.Use(ocsm.ParseForm) // decodes form, multipart and json bodies of all mutating requests
+			r.Route("/apps/files_sharing/api/v1", func(r chi.Router) {
+				r.Route("/shares", func(r chi.Router) {
+					r.Get("/", svc.ListShares)
+					r.Post("/", svc.CreateShare)
+					r.Get("/{shareid}", svc.GetShare)
+					r.Put("/{shareid}", svc.UpdateShare)
+					r.Delete("/{shareid}", svc.RemoveShare)
+				})
+				r.Get("/sharees", svc.ListSharees)
+			})
+			r.Route("/apps/notifications/api/v1", func(r chi.Router) {
+				r.Route("/notifications", func(r chi.Router) {
+					r.Get("/", svc.ListNotifications)
+					r.Delete("/", svc.DeleteAllNotifications)
+					r.Get("/{notificationid}", svc.GetNotification)
+					r.Delete("/{notificationid}", svc.DeleteNotification)
+				})
+				r.With(svc.requireAdmin).Post("/admin_notifications/{userid}", svc.PublishNotification)
+			})
 			r.Route("/cloud", func(r chi.Router) {
-				r.Route("/capabilities", func(r chi.Router) {})
+				r.Route("/capabilities", func(r chi.Router) {
+					r.Get("/", svc.GetCapabilities)
+				})
 				r.Route("/user", func(r chi.Router) {
+					r.Use(svc.requireUser)
 					r.Get("/", svc.GetUser)
+					r.Put("/", svc.EditCurrentUser)
 					r.Get("/signing-key", svc.GetSigningKey)
 				})
 				r.Route("/users", func(r chi.Router) {
-					r.Get("/", svc.ListUsers)
-					r.Post("/", svc.AddUser)
-					r.Get("/{userid}", svc.GetUser)
-					r.Put("/{userid}", svc.EditUser)
-					r.Delete("/{userid}", svc.DeleteUser)
+					r.With(svc.requireSubadmin).Get("/", svc.ListUsers)
+					r.With(svc.requireSubadmin).Post("/", svc.AddUser)
+					r.With(svc.requireSubadmin).Post("/batch", svc.AddUsers)
+					r.With(svc.requireUserManager(true)).Get("/{userid}", svc.GetUser)
+					r.With(svc.requireUserManager(true)).Put("/{userid}", svc.EditUser)
+					r.With(svc.requireUserManager(false)).Delete("/{userid}", svc.DeleteUser)
+					r.With(svc.requireUserManager(false)).Put("/{userid}/enable", svc.EnableUser)
+					r.With(svc.requireUserManager(false)).Put("/{userid}/disable", svc.DisableUser)
 
 					r.Route("/{userid}/groups", func(r chi.Router) {
-						r.Get("/", svc.ListUserGroups)
-						r.Post("/", svc.AddToGroup)
-						r.Delete("/", svc.RemoveFromGroup)
+						r.With(svc.requireUserManager(true)).Get("/", svc.ListUserGroups)
+						r.With(svc.requireGroupManager).Post("/", svc.AddToGroup)
+						r.With(svc.requireGroupManager).Delete("/", svc.RemoveFromGroup)
+					})
+					r.Route("/{userid}/subadmins", func(r chi.Router) {
+						r.Use(svc.requireAdmin)
+						r.Get("/", svc.ListUserSubadminGroups)
+						r.Post("/", svc.AddSubadmin)
+						r.Delete("/", svc.RemoveSubadmin)
 					})
 				})
 				r.Route("/groups", func(r chi.Router) {
+					r.Use(svc.requireAdmin)
 					r.Get("/", svc.ListGroups)
 					r.Post("/", svc.AddGroup)
+					r.Put("/{groupid}", svc.EditGroup)
 					r.Delete("/{groupid}", svc.DeleteGroup)
 					r.Get("/{groupid}", svc.GetGroupMembers)
+					r.Get("/{groupid}/subadmins", svc.ListGroupSubadmins)
 				})
 			})
 			r.Route("/config", func(r chi.Router) {
@@ -86,9 +154,15 @@ func NewService(opts ...Option) Service {
 
 // Ocs defines implements the business logic for Service.
 type Ocs struct {
-	config *config.Config
-	logger log.Logger
-	mux    *chi.Mux
+	config       *config.Config
+	logger       log.Logger
+	mux          *chi.Mux
+	capabilities *data.Capabilities
+	passwords    *passwordPolicy
+	client       client.Client
+	gateway      gateway.GatewayAPIClient
+	store        storepb.StoreService
+	roles        settings.RoleService
 }
 
 // ServeHTTP implements the Service interface.
@@ -102,9 +176,30 @@ func (o Ocs) NotFound(w http.ResponseWriter, r *http.Request) {
 }
 
 func (o Ocs) getAccountService() accounts.AccountsService {
-	return accounts.NewAccountsService("com.owncloud.api.accounts", defaultClient)
+	return accounts.NewAccountsService("com.owncloud.api.accounts", o.client)
 }
 
 func (o Ocs) getGroupsService() accounts.GroupsService {
-	return accounts.NewGroupsService("com.owncloud.api.accounts", defaultClient)
+	return accounts.NewGroupsService("com.owncloud.api.accounts", o.client)
+}
+
+func (o Ocs) getRoleService() settings.RoleService {
+	if o.roles != nil {
+		return o.roles
+	}
+	return settings.NewRoleService("com.owncloud.api.settings", o.client)
+}
+
+func (o Ocs) getStoreService() storepb.StoreService {
+	if o.store != nil {
+		return o.store
+	}
+	return storepb.NewStoreService("com.owncloud.api.store", o.client)
+}
+
+func (o Ocs) getGatewayClient() (gateway.GatewayAPIClient, error) {
+	if o.gateway != nil {
+		return o.gateway, nil
+	}
+	return pool.GetGatewayServiceClient(o.config.Reva.Address)
 }
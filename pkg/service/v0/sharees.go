@@ -0,0 +1,243 @@
+package svc
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cs3org/reva/pkg/user"
+	"github.com/go-chi/render"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+// defaultShareesPerPage is the number of non exact matches returned when no perPage parameter is given
+const defaultShareesPerPage = 200
+
+// ListSharees searches the users and groups the current user can share with.
+// Exact matches are always returned, users and groups whose name, display name or email start with the search term
+// only if user enumeration is enabled and the search term is long enough. The itemType parameter is accepted for
+// compatibility, remotes are always empty because federated sharing is not supported.
+func (o Ocs) ListSharees(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	search := strings.TrimSpace(q.Get("search"))
+
+	page := 1
+	if v := q.Get("page"); v != "" {
+		var err error
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "invalid page"))
+			return
+		}
+	}
+	perPage := defaultShareesPerPage
+	if v := q.Get("perPage"); v != "" {
+		var err error
+		if perPage, err = strconv.Atoi(v); err != nil || perPage < 1 {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "invalid perPage argument"))
+			return
+		}
+	}
+
+	u, ok := user.ContextGetUser(r.Context())
+	if !ok || u.GetId().GetOpaqueId() == "" {
+		render.Render(w, r, response.ErrRender(data.MetaUnauthorized.StatusCode, "missing user in context"))
+		return
+	}
+
+	caller, err := o.getAccountService().GetAccount(r.Context(), &accounts.GetAccountRequest{
+		Id: u.Id.OpaqueId,
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not get current user")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get current user"))
+		return
+	}
+	callerGroups := map[string]bool{}
+	for i := range caller.MemberOf {
+		callerGroups[caller.MemberOf[i].Id] = true
+	}
+
+	sharing := o.capabilities.FilesSharing
+	enumerate := bool(sharing.UserEnumeration.Enabled) && len(search) >= sharing.SearchMinLength
+
+	sharees := &data.Sharees{
+		Exact: &data.ExactSharees{
+			Users:   []*data.Sharee{},
+			Groups:  []*data.Sharee{},
+			Remotes: []*data.Sharee{},
+		},
+		Users:   []*data.Sharee{},
+		Groups:  []*data.Sharee{},
+		Remotes: []*data.Sharee{},
+	}
+
+	if search == "" {
+		render.Render(w, r, response.DataRender(sharees))
+		return
+	}
+
+	ares, err := o.getAccountService().ListAccounts(r.Context(), &accounts.ListAccountsRequest{
+		Query: shareeQuery(search, enumerate,
+			[]string{"id", "on_premises_sam_account_name", "preferred_name", "mail"},
+			[]string{"on_premises_sam_account_name", "preferred_name", "display_name", "mail"},
+		),
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not list users")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list users"))
+		return
+	}
+
+	for _, a := range ares.Accounts {
+		if a.Id == caller.Id || !a.AccountEnabled {
+			continue
+		}
+		sharesGroup := memberOfAny(a, callerGroups)
+		if bool(sharing.ShareWithGroupMembersOnly) && !sharesGroup {
+			continue
+		}
+
+		switch {
+		case accountMatchesExactly(a, search):
+			sharees.Exact.Users = append(sharees.Exact.Users, accountSharee(a))
+		case enumerate && (!bool(sharing.UserEnumeration.GroupMembersOnly) || sharesGroup):
+			sharees.Users = append(sharees.Users, accountSharee(a))
+		}
+	}
+
+	if sharing.GroupSharing {
+		gres, err := o.getGroupsService().ListGroups(r.Context(), &accounts.ListGroupsRequest{
+			Query: shareeQuery(search, enumerate,
+				[]string{"id", "on_premises_sam_account_name", "display_name"},
+				[]string{"on_premises_sam_account_name", "display_name"},
+			),
+		})
+		if err != nil {
+			o.logger.Error().Err(err).Msg("could not list groups")
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list groups"))
+			return
+		}
+
+		for _, g := range gres.Groups {
+			if bool(sharing.ShareWithMembershipGroupsOnly) && !callerGroups[g.Id] {
+				continue
+			}
+
+			switch {
+			case groupMatchesExactly(g, search):
+				sharees.Exact.Groups = append(sharees.Exact.Groups, groupSharee(g))
+			case enumerate && (!bool(sharing.UserEnumeration.GroupMembersOnly) || callerGroups[g.Id]):
+				sharees.Groups = append(sharees.Groups, groupSharee(g))
+			}
+		}
+	}
+
+	sharees.Users = pageSharees(sharees.Users, page, perPage)
+	sharees.Groups = pageSharees(sharees.Groups, page, perPage)
+
+	o.logger.Debug().Str("search", search).Int("users", len(sharees.Users)).Int("groups", len(sharees.Groups)).Msg("listing sharees")
+	render.Render(w, r, response.DataRender(sharees))
+}
+
+// shareeQuery builds the accounts query of a sharee search, so that the accounts service does not return every
+// account. The fields in exact are compared to the search term, the fields in prefix are only searched when the
+// results are enumerated. The accounts index matches lower cased terms.
+func shareeQuery(search string, enumerate bool, exact, prefix []string) string {
+	term := escapeValue(strings.ToLower(search))
+	clauses := make([]string, 0, len(exact)+len(prefix))
+	for _, field := range exact {
+		clauses = append(clauses, fmt.Sprintf("%s eq '%s'", field, term))
+	}
+	if enumerate {
+		for _, field := range prefix {
+			clauses = append(clauses, fmt.Sprintf("startswith(%s,'%s')", field, term))
+		}
+	}
+	return strings.Join(clauses, " or ")
+}
+
+// searchQuery builds the accounts query of a user or group search, which matches the beginning of the fields. The
+// accounts service lists everything for an empty query.
+func searchQuery(search string, fields ...string) string {
+	if search == "" {
+		return ""
+	}
+	return shareeQuery(search, true, nil, fields)
+}
+
+// memberOfAny checks if the account is a member of at least one of the groups
+func memberOfAny(a *accounts.Account, groups map[string]bool) bool {
+	for i := range a.MemberOf {
+		if groups[a.MemberOf[i].Id] {
+			return true
+		}
+	}
+	return false
+}
+
+// accountMatchesExactly checks if the search is the id, username or email of the account, ignoring case
+func accountMatchesExactly(a *accounts.Account, search string) bool {
+	return strings.EqualFold(a.Id, search) ||
+		strings.EqualFold(a.OnPremisesSamAccountName, search) ||
+		strings.EqualFold(a.PreferredName, search) ||
+		strings.EqualFold(a.Mail, search)
+}
+
+// groupMatchesExactly checks if the search is the id or name of the group, ignoring case
+func groupMatchesExactly(g *accounts.Group, search string) bool {
+	return strings.EqualFold(g.Id, search) ||
+		strings.EqualFold(g.OnPremisesSamAccountName, search) ||
+		strings.EqualFold(g.DisplayName, search)
+}
+
+func accountSharee(a *accounts.Account) *data.Sharee {
+	label := a.DisplayName
+	if label == "" {
+		label = a.OnPremisesSamAccountName
+	}
+	return &data.Sharee{
+		Label: label,
+		Value: &data.ShareeValue{
+			ShareType:               data.ShareTypeUser,
+			ShareWith:               a.Id,
+			ShareWithAdditionalInfo: a.Mail,
+		},
+	}
+}
+
+func groupSharee(g *accounts.Group) *data.Sharee {
+	label := g.DisplayName
+	if label == "" {
+		label = g.OnPremisesSamAccountName
+	}
+	return &data.Sharee{
+		Label: label,
+		Value: &data.ShareeValue{
+			ShareType: data.ShareTypeGroup,
+			ShareWith: g.Id,
+		},
+	}
+}
+
+// pageSharees sorts the sharees by label and returns the requested page
+func pageSharees(sharees []*data.Sharee, page, perPage int) []*data.Sharee {
+	sort.SliceStable(sharees, func(i, j int) bool {
+		return strings.ToLower(sharees[i].Label) < strings.ToLower(sharees[j].Label)
+	})
+
+	// page and perPage are only bounded below, compare them to the number of pages instead of multiplying
+	if len(sharees) == 0 || page-1 > (len(sharees)-1)/perPage {
+		return []*data.Sharee{}
+	}
+	start := (page - 1) * perPage
+	end := len(sharees)
+	if perPage < end-start {
+		end = start + perPage
+	}
+	return sharees[start:end]
+}
@@ -0,0 +1,637 @@
+package svc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/user"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	merrors "github.com/micro/go-micro/v2/errors"
+
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+)
+
+// ListShares lists the shares created by the current user, optionally filtered by path.
+// With shared_with_me=true the shares received by the current user are listed instead.
+func (o Ocs) ListShares(w http.ResponseWriter, r *http.Request) {
+	gwc, err := o.getGatewayClient()
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not get gateway client")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get gateway client"))
+		return
+	}
+
+	if r.URL.Query().Get("shared_with_me") == "true" {
+		o.listReceivedShares(w, r, gwc)
+		return
+	}
+
+	filters := []*collaboration.ListSharesRequest_Filter{}
+	var info *provider.ResourceInfo
+	if p := r.URL.Query().Get("path"); p != "" {
+		var ok bool
+		if info, ok = o.statPath(w, r, gwc, p); !ok {
+			return
+		}
+		filters = append(filters, &collaboration.ListSharesRequest_Filter{
+			Type: collaboration.ListSharesRequest_Filter_TYPE_RESOURCE_ID,
+			Term: &collaboration.ListSharesRequest_Filter_ResourceId{
+				ResourceId: info.Id,
+			},
+		})
+	}
+
+	res, err := gwc.ListShares(r.Context(), &collaboration.ListSharesRequest{
+		Filters: filters,
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not list shares")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list shares"))
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Msg("could not list shares")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list shares"))
+		return
+	}
+
+	shares := make([]*data.ShareData, 0, len(res.Shares))
+	for i := range res.Shares {
+		sd, err := o.shareData(r.Context(), gwc, res.Shares[i], info)
+		if err != nil {
+			o.logger.Error().Err(err).Str("shareid", res.Shares[i].GetId().GetOpaqueId()).Msg("could not convert share, skipping")
+			continue
+		}
+		shares = append(shares, sd)
+	}
+
+	links, ok := o.listPublicShares(w, r, gwc, info)
+	if !ok {
+		return
+	}
+	shares = append(shares, links...)
+
+	render.Render(w, r, response.DataRender(shares))
+}
+
+func (o Ocs) listReceivedShares(w http.ResponseWriter, r *http.Request, gwc gateway.GatewayAPIClient) {
+	res, err := gwc.ListReceivedShares(r.Context(), &collaboration.ListReceivedSharesRequest{})
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not list received shares")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list received shares"))
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Msg("could not list received shares")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list received shares"))
+		return
+	}
+
+	shares := make([]*data.ShareData, 0, len(res.Shares))
+	for i := range res.Shares {
+		sd, err := o.shareData(r.Context(), gwc, res.Shares[i].GetShare(), nil)
+		if err != nil {
+			o.logger.Error().Err(err).Str("shareid", res.Shares[i].GetShare().GetId().GetOpaqueId()).Msg("could not convert received share, skipping")
+			continue
+		}
+		switch res.Shares[i].GetState() {
+		case collaboration.ShareState_SHARE_STATE_PENDING:
+			sd.State = data.ShareStatePending
+		case collaboration.ShareState_SHARE_STATE_REJECTED:
+			sd.State = data.ShareStateRejected
+		default:
+			sd.State = data.ShareStateAccepted
+		}
+		shares = append(shares, sd)
+	}
+
+	render.Render(w, r, response.DataRender(shares))
+}
+
+// CreateShare creates a new share
+func (o Ocs) CreateShare(w http.ResponseWriter, r *http.Request) {
+	shareType, err := strconv.Atoi(r.PostFormValue("shareType"))
+	if err != nil {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "shareType must be an integer"))
+		return
+	}
+
+	switch shareType {
+	case data.ShareTypeUser, data.ShareTypeGroup:
+		o.createUserShare(w, r, shareType)
+	case data.ShareTypePublicLink:
+		o.createPublicShare(w, r)
+	default:
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "unknown share type"))
+	}
+}
+
+// createUserShare creates a share with a user or a group
+func (o Ocs) createUserShare(w http.ResponseWriter, r *http.Request, shareType int) {
+	p := r.PostFormValue("path")
+	shareWith := r.PostFormValue("shareWith")
+
+	if p == "" {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "please specify a file or folder path"))
+		return
+	}
+	if shareWith == "" {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "please specify a share recipient"))
+		return
+	}
+	if shareType == data.ShareTypeGroup && !o.capabilities.FilesSharing.GroupSharing {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "group sharing is disabled"))
+		return
+	}
+
+	permissions := o.capabilities.FilesSharing.DefaultPermissions
+	if v := r.PostFormValue("permissions"); v != "" {
+		var err error
+		if permissions, err = parsePermissions(v); err != nil {
+			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, err.Error()))
+			return
+		}
+	}
+	if permissions&data.PermissionRead == 0 {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "shares need at least read permissions"))
+		return
+	}
+
+	grantee, err := o.lookupGrantee(r.Context(), shareType, shareWith)
+	if err != nil {
+		merr := merrors.FromError(err)
+		if merr.Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "the share recipient could not be found"))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("sharewith", shareWith).Msg("could not look up share recipient")
+		return
+	}
+
+	if u, ok := user.ContextGetUser(r.Context()); ok && shareType == data.ShareTypeUser && u.GetId().GetOpaqueId() == grantee.Id.OpaqueId {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "cannot share with yourself"))
+		return
+	}
+
+	gwc, err := o.getGatewayClient()
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not get gateway client")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get gateway client"))
+		return
+	}
+
+	info, ok := o.statPath(w, r, gwc, p)
+	if !ok {
+		return
+	}
+
+	if info.Type != provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+		// files cannot be shared with create or delete permissions
+		permissions &^= data.PermissionCreate | data.PermissionDelete
+	}
+
+	res, err := gwc.CreateShare(r.Context(), &collaboration.CreateShareRequest{
+		ResourceInfo: info,
+		Grant: &collaboration.ShareGrant{
+			Grantee: grantee,
+			Permissions: &collaboration.SharePermissions{
+				Permissions: cs3Permissions(permissions),
+			},
+		},
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("path", p).Str("sharewith", shareWith).Msg("could not create share")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not create share"))
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("path", p).Str("sharewith", shareWith).Msg("could not create share")
+		renderRPCStatus(w, r, res.Status, "could not create share")
+		return
+	}
+
+	sd, err := o.shareData(r.Context(), gwc, res.Share, info)
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", res.Share.GetId().GetOpaqueId()).Msg("could not convert share")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not convert share"))
+		return
+	}
+
+	o.logger.Debug().Str("shareid", sd.ID).Str("path", p).Str("sharewith", shareWith).Msg("created share")
+	render.Render(w, r, response.DataRender(sd))
+}
+
+// GetShare returns a single share
+func (o Ocs) GetShare(w http.ResponseWriter, r *http.Request) {
+	shareID := chi.URLParam(r, "shareid")
+
+	gwc, err := o.getGatewayClient()
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not get gateway client")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get gateway client"))
+		return
+	}
+
+	if ps, ok := o.getPublicShare(r.Context(), gwc, shareID); ok {
+		sd, err := o.publicShareData(r.Context(), gwc, ps, nil)
+		if err != nil {
+			o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not convert public share")
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not convert share"))
+			return
+		}
+		render.Render(w, r, response.DataRender([]*data.ShareData{sd}))
+		return
+	}
+
+	res, err := gwc.GetShare(r.Context(), &collaboration.GetShareRequest{
+		Ref: shareRef(shareID),
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not get share")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get share"))
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("shareid", shareID).Msg("could not get share")
+		renderRPCStatus(w, r, res.Status, "share not found")
+		return
+	}
+
+	sd, err := o.shareData(r.Context(), gwc, res.Share, nil)
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not convert share")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not convert share"))
+		return
+	}
+
+	// oc10 returns a list containing the single share
+	render.Render(w, r, response.DataRender([]*data.ShareData{sd}))
+}
+
+// UpdateShare changes the permissions of a share. Public links can also change their password, expiration and name.
+func (o Ocs) UpdateShare(w http.ResponseWriter, r *http.Request) {
+	shareID := chi.URLParam(r, "shareid")
+
+	gwc, err := o.getGatewayClient()
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not get gateway client")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get gateway client"))
+		return
+	}
+
+	if ps, ok := o.getPublicShare(r.Context(), gwc, shareID); ok {
+		o.updatePublicShare(w, r, gwc, ps)
+		return
+	}
+
+	v := r.PostFormValue("permissions")
+	if v == "" {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "wrong or no update parameter given"))
+		return
+	}
+	permissions, err := parsePermissions(v)
+	if err != nil {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, err.Error()))
+		return
+	}
+	if permissions&data.PermissionRead == 0 {
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "shares need at least read permissions"))
+		return
+	}
+
+	current, err := gwc.GetShare(r.Context(), &collaboration.GetShareRequest{
+		Ref: shareRef(shareID),
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not get share")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get share"))
+		return
+	}
+	if current.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Debug().Str("code", current.Status.Code.String()).Str("message", current.Status.Message).Str("shareid", shareID).Msg("could not get share")
+		renderRPCStatus(w, r, current.Status, "share not found")
+		return
+	}
+	info, err := o.statID(r.Context(), gwc, current.Share.GetResourceId())
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not stat shared resource")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not stat shared resource"))
+		return
+	}
+	if info.Type != provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+		// files cannot be shared with create or delete permissions
+		permissions &^= data.PermissionCreate | data.PermissionDelete
+	}
+
+	res, err := gwc.UpdateShare(r.Context(), &collaboration.UpdateShareRequest{
+		Ref: shareRef(shareID),
+		Field: &collaboration.UpdateShareRequest_UpdateField{
+			Field: &collaboration.UpdateShareRequest_UpdateField_Permissions{
+				Permissions: &collaboration.SharePermissions{
+					Permissions: cs3Permissions(permissions),
+				},
+			},
+		},
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not update share")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not update share"))
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("shareid", shareID).Msg("could not update share")
+		renderRPCStatus(w, r, res.Status, "could not update share")
+		return
+	}
+
+	sd, err := o.shareData(r.Context(), gwc, res.Share, info)
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not convert share")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not convert share"))
+		return
+	}
+
+	o.logger.Debug().Str("shareid", shareID).Int("permissions", permissions).Msg("updated share")
+	render.Render(w, r, response.DataRender(sd))
+}
+
+// RemoveShare deletes a share
+func (o Ocs) RemoveShare(w http.ResponseWriter, r *http.Request) {
+	shareID := chi.URLParam(r, "shareid")
+
+	gwc, err := o.getGatewayClient()
+	if err != nil {
+		o.logger.Error().Err(err).Msg("could not get gateway client")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not get gateway client"))
+		return
+	}
+
+	if _, ok := o.getPublicShare(r.Context(), gwc, shareID); ok {
+		o.removePublicShare(w, r, gwc, shareID)
+		return
+	}
+
+	res, err := gwc.RemoveShare(r.Context(), &collaboration.RemoveShareRequest{
+		Ref: shareRef(shareID),
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("shareid", shareID).Msg("could not remove share")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not remove share"))
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Error().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("shareid", shareID).Msg("could not remove share")
+		renderRPCStatus(w, r, res.Status, "share not found")
+		return
+	}
+
+	o.logger.Debug().Str("shareid", shareID).Msg("removed share")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
+// statPath looks up the resource info for a path relative to the users home.
+// If the resource cannot be found an ocs error is rendered and false is returned.
+func (o Ocs) statPath(w http.ResponseWriter, r *http.Request, gwc gateway.GatewayAPIClient, p string) (*provider.ResourceInfo, bool) {
+	res, err := gwc.Stat(r.Context(), &provider.StatRequest{
+		Ref: &provider.Reference{
+			Spec: &provider.Reference_Path{Path: path.Join(o.config.Reva.HomeNamespace, p)},
+		},
+	})
+	if err != nil {
+		o.logger.Error().Err(err).Str("path", p).Msg("could not stat path")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not stat path"))
+		return nil, false
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		o.logger.Debug().Str("code", res.Status.Code.String()).Str("message", res.Status.Message).Str("path", p).Msg("could not stat path")
+		renderRPCStatus(w, r, res.Status, "wrong path, file/folder doesn't exist")
+		return nil, false
+	}
+	return res.Info, true
+}
+
+// shareData converts a cs3 share into the oc10 share format. If the resource info is nil it is looked up.
+func (o Ocs) shareData(ctx context.Context, gwc gateway.GatewayAPIClient, s *collaboration.Share, info *provider.ResourceInfo) (*data.ShareData, error) {
+	sd := &data.ShareData{
+		ID:           s.GetId().GetOpaqueId(),
+		Permissions:  ocsPermissions(s.GetPermissions().GetPermissions()),
+		STime:        s.GetCtime().GetSeconds(),
+		UIDOwner:     s.GetCreator().GetOpaqueId(),
+		UIDFileOwner: s.GetOwner().GetOpaqueId(),
+		ShareWith:    s.GetGrantee().GetId().GetOpaqueId(),
+	}
+
+	if s.GetGrantee().GetType() == provider.GranteeType_GRANTEE_TYPE_GROUP {
+		sd.ShareType = data.ShareTypeGroup
+		sd.ShareWithDisplayname = o.groupDisplayName(ctx, sd.ShareWith)
+	} else {
+		sd.ShareType = data.ShareTypeUser
+		sd.ShareWithDisplayname = o.accountDisplayName(ctx, sd.ShareWith)
+	}
+
+	if err := o.completeShareData(ctx, gwc, sd, s.GetResourceId(), info); err != nil {
+		return nil, err
+	}
+	return sd, nil
+}
+
+// completeShareData fills the owner display names and the resource related fields of the share data.
+// If the resource info is nil it is looked up.
+func (o Ocs) completeShareData(ctx context.Context, gwc gateway.GatewayAPIClient, sd *data.ShareData, id *provider.ResourceId, info *provider.ResourceInfo) error {
+	sd.DisplaynameOwner = o.accountDisplayName(ctx, sd.UIDOwner)
+	sd.DisplaynameFileOwner = o.accountDisplayName(ctx, sd.UIDFileOwner)
+
+	if info == nil {
+		var err error
+		if info, err = o.statID(ctx, gwc, id); err != nil {
+			return err
+		}
+	}
+
+	sd.Path = o.relativePath(info.GetPath())
+	sd.FileTarget = path.Join("/", path.Base(info.GetPath()))
+	sd.MimeType = info.GetMimeType()
+	sd.StorageID = info.GetId().GetStorageId()
+	sd.ItemSource = wrapResourceID(info.GetId())
+	sd.FileSource = sd.ItemSource
+	if info.GetType() == provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+		sd.ItemType = "folder"
+	} else {
+		sd.ItemType = "file"
+	}
+	return nil
+}
+
+// statID looks up the resource info for a resource id
+func (o Ocs) statID(ctx context.Context, gwc gateway.GatewayAPIClient, id *provider.ResourceId) (*provider.ResourceInfo, error) {
+	res, err := gwc.Stat(ctx, &provider.StatRequest{
+		Ref: &provider.Reference{
+			Spec: &provider.Reference_Id{Id: id},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return nil, fmt.Errorf("could not stat shared resource %s: %s", wrapResourceID(id), res.Status.Message)
+	}
+	return res.Info, nil
+}
+
+// relativePath strips the home namespace from a path
+func (o Ocs) relativePath(p string) string {
+	p = strings.TrimPrefix(p, o.config.Reva.HomeNamespace)
+	return path.Join("/", p)
+}
+
+// lookupGrantee resolves the share recipient by id or name
+func (o Ocs) lookupGrantee(ctx context.Context, shareType int, shareWith string) (*provider.Grantee, error) {
+	if shareType == data.ShareTypeGroup {
+		group, err := o.lookupGroup(ctx, shareWith)
+		if err != nil {
+			return nil, err
+		}
+		return &provider.Grantee{
+			Type: provider.GranteeType_GRANTEE_TYPE_GROUP,
+			Id:   &userpb.UserId{OpaqueId: group.Id},
+		}, nil
+	}
+
+	account, err := o.lookupAccount(ctx, shareWith)
+	if err != nil {
+		return nil, err
+	}
+	return &provider.Grantee{
+		Type: provider.GranteeType_GRANTEE_TYPE_USER,
+		Id:   &userpb.UserId{OpaqueId: account.Id},
+	}, nil
+}
+
+// accountDisplayName returns the display name of an account, falling back to the id
+func (o Ocs) accountDisplayName(ctx context.Context, id string) string {
+	if id == "" {
+		return ""
+	}
+	account, err := o.lookupAccount(ctx, id)
+	if err != nil || account.DisplayName == "" {
+		return id
+	}
+	return account.DisplayName
+}
+
+// groupDisplayName returns the display name of a group, falling back to the id
+func (o Ocs) groupDisplayName(ctx context.Context, id string) string {
+	if id == "" {
+		return ""
+	}
+	group, err := o.lookupGroup(ctx, id)
+	if err != nil || group.DisplayName == "" {
+		return id
+	}
+	return group.DisplayName
+}
+
+// renderRPCStatus renders the ocs error matching a cs3 status
+func renderRPCStatus(w http.ResponseWriter, r *http.Request, s *rpc.Status, msg string) {
+	switch s.Code {
+	case rpc.Code_CODE_NOT_FOUND:
+		render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, msg))
+	case rpc.Code_CODE_PERMISSION_DENIED:
+		render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, msg))
+	case rpc.Code_CODE_INVALID_ARGUMENT, rpc.Code_CODE_ALREADY_EXISTS:
+		render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, msg))
+	default:
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, msg))
+	}
+}
+
+func shareRef(id string) *collaboration.ShareReference {
+	return &collaboration.ShareReference{
+		Spec: &collaboration.ShareReference_Id{
+			Id: &collaboration.ShareId{OpaqueId: id},
+		},
+	}
+}
+
+// wrapResourceID encodes a resource id the same way the webdav endpoint does for the oc:fileid property
+func wrapResourceID(id *provider.ResourceId) string {
+	return base64.URLEncoding.EncodeToString([]byte(id.GetStorageId() + ":" + id.GetOpaqueId()))
+}
+
+// parsePermissions parses an ocs permission bitmask
+func parsePermissions(v string) (int, error) {
+	p, err := strconv.Atoi(v)
+	if err != nil || p < 1 || p > data.PermissionAll {
+		return 0, fmt.Errorf("permissions must be an integer between 1 and %d", data.PermissionAll)
+	}
+	return p, nil
+}
+
+// cs3Permissions converts an ocs permission bitmask into cs3 resource permissions
+func cs3Permissions(p int) *provider.ResourcePermissions {
+	rp := &provider.ResourcePermissions{}
+	if p&data.PermissionRead != 0 {
+		rp.GetPath = true
+		rp.GetQuota = true
+		rp.InitiateFileDownload = true
+		rp.ListContainer = true
+		rp.ListFileVersions = true
+		rp.ListGrants = true
+		rp.ListRecycle = true
+		rp.Stat = true
+	}
+	if p&data.PermissionUpdate != 0 {
+		rp.InitiateFileUpload = true
+		rp.Move = true
+		rp.RestoreFileVersion = true
+		rp.RestoreRecycleItem = true
+	}
+	if p&data.PermissionCreate != 0 {
+		rp.CreateContainer = true
+		rp.InitiateFileUpload = true
+	}
+	if p&data.PermissionDelete != 0 {
+		rp.Delete = true
+		rp.PurgeRecycle = true
+	}
+	if p&data.PermissionShare != 0 {
+		rp.AddGrant = true
+		rp.RemoveGrant = true
+		rp.UpdateGrant = true
+	}
+	return rp
+}
+
+// ocsPermissions converts cs3 resource permissions into an ocs permission bitmask
+func ocsPermissions(rp *provider.ResourcePermissions) int {
+	p := 0
+	if rp.GetStat() {
+		p |= data.PermissionRead
+	}
+	if rp.GetMove() {
+		p |= data.PermissionUpdate
+	}
+	if rp.GetCreateContainer() {
+		p |= data.PermissionCreate
+	}
+	if rp.GetDelete() {
+		p |= data.PermissionDelete
+	}
+	if rp.GetAddGrant() {
+		p |= data.PermissionShare
+	}
+	return p
+}
@@ -0,0 +1,257 @@
+package svc
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	merrors "github.com/micro/go-micro/v2/errors"
+
+	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
+	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
+	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
+)
+
+const (
+	subadminDatabase = "ocs"
+	subadminTable    = "subadmins"
+)
+
+// ListUserSubadminGroups lists the groups a user is subadmin of
+func (o Ocs) ListUserSubadminGroups(w http.ResponseWriter, r *http.Request) {
+	userid := chi.URLParam(r, "userid")
+
+	if _, err := o.getAccountService().GetAccount(r.Context(), &accounts.GetAccountRequest{Id: userid}); err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaFailure.StatusCode, "User does not exist"))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("userid", userid).Msg("could not get user")
+		return
+	}
+
+	groups, err := o.listSubadminGroups(r.Context(), userid)
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", userid).Msg("could not list subadmin groups")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list subadmin groups"))
+		return
+	}
+
+	render.Render(w, r, response.DataRender(groups))
+}
+
+// AddSubadmin makes a user subadmin of a group
+func (o Ocs) AddSubadmin(w http.ResponseWriter, r *http.Request) {
+	userid := chi.URLParam(r, "userid")
+	groupid := r.PostFormValue("groupid")
+
+	if _, err := o.getAccountService().GetAccount(r.Context(), &accounts.GetAccountRequest{Id: userid}); err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaFailure.StatusCode, "User does not exist"))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("userid", userid).Msg("could not get user")
+		return
+	}
+
+	if groupid == "" {
+		render.Render(w, r, response.ErrRender(data.MetaInvalidInput.StatusCode, "Group does not exist"))
+		return
+	}
+	if _, err := o.getGroupsService().GetGroup(r.Context(), &accounts.GetGroupRequest{Id: groupid}); err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaInvalidInput.StatusCode, "Group:"+groupid+" does not exist"))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("groupid", groupid).Msg("could not get group")
+		return
+	}
+
+	if err := o.addSubadmin(r.Context(), userid, groupid); err != nil {
+		o.logger.Error().Err(err).Str("userid", userid).Str("groupid", groupid).Msg("could not add subadmin")
+		render.Render(w, r, response.ErrRender(103, "Unknown error occurred"))
+		return
+	}
+
+	o.logger.Debug().Str("userid", userid).Str("groupid", groupid).Msg("added subadmin")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
+// RemoveSubadmin revokes the subadmin rights of a user for a group
+func (o Ocs) RemoveSubadmin(w http.ResponseWriter, r *http.Request) {
+	userid := chi.URLParam(r, "userid")
+	groupid := groupIDParam(r)
+
+	groups, err := o.listSubadminGroups(r.Context(), userid)
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", userid).Msg("could not list subadmin groups")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list subadmin groups"))
+		return
+	}
+
+	found := false
+	for _, g := range groups {
+		if g == groupid {
+			found = true
+			break
+		}
+	}
+	if !found {
+		render.Render(w, r, response.ErrRender(data.MetaInvalidInput.StatusCode, "User is not a subadmin of this group"))
+		return
+	}
+
+	if err := o.removeSubadmin(r.Context(), userid, groupid); err != nil {
+		o.logger.Error().Err(err).Str("userid", userid).Str("groupid", groupid).Msg("could not remove subadmin")
+		render.Render(w, r, response.ErrRender(103, "Unknown error occurred"))
+		return
+	}
+
+	o.logger.Debug().Str("userid", userid).Str("groupid", groupid).Msg("removed subadmin")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
+// ListGroupSubadmins lists the subadmins of a group
+func (o Ocs) ListGroupSubadmins(w http.ResponseWriter, r *http.Request) {
+	groupid := chi.URLParam(r, "groupid")
+
+	if _, err := o.getGroupsService().GetGroup(r.Context(), &accounts.GetGroupRequest{Id: groupid}); err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaFailure.StatusCode, "Group does not exist"))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("groupid", groupid).Msg("could not get group")
+		return
+	}
+
+	users, err := o.listGroupSubadmins(r.Context(), groupid)
+	if err != nil {
+		o.logger.Error().Err(err).Str("groupid", groupid).Msg("could not list subadmins")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not list subadmins"))
+		return
+	}
+
+	render.Render(w, r, response.DataRender(users))
+}
+
+// Subadmin assignments are stored twice, once per user and once per group, so that both can be listed with a
+// single prefix query.
+func subadminUserKey(userid, groupid string) string {
+	return "user/" + userid + "/" + groupid
+}
+
+func subadminGroupKey(groupid, userid string) string {
+	return "group/" + groupid + "/" + userid
+}
+
+// listSubadminGroups returns the ids of the groups administered by the user
+func (o Ocs) listSubadminGroups(ctx context.Context, userid string) ([]string, error) {
+	return o.readSubadminKeys(ctx, subadminUserKey(userid, ""))
+}
+
+// listGroupSubadmins returns the ids of the subadmins of the group
+func (o Ocs) listGroupSubadmins(ctx context.Context, groupid string) ([]string, error) {
+	return o.readSubadminKeys(ctx, subadminGroupKey(groupid, ""))
+}
+
+// readSubadminKeys returns the last key segment of all records with the prefix, sorted
+func (o Ocs) readSubadminKeys(ctx context.Context, prefix string) ([]string, error) {
+	res, err := o.getStoreService().Read(ctx, &storepb.ReadRequest{
+		Options: &storepb.ReadOptions{
+			Database: subadminDatabase,
+			Table:    subadminTable,
+			Prefix:   true,
+		},
+		Key: prefix,
+	})
+	if err != nil {
+		if merrors.Parse(err.Error()).Code == http.StatusNotFound {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(res.Records))
+	for _, record := range res.Records {
+		ids = append(ids, strings.TrimPrefix(record.Key, prefix))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (o Ocs) addSubadmin(ctx context.Context, userid, groupid string) error {
+	for _, key := range []string{subadminUserKey(userid, groupid), subadminGroupKey(groupid, userid)} {
+		_, err := o.getStoreService().Write(ctx, &storepb.WriteRequest{
+			Options: &storepb.WriteOptions{
+				Database: subadminDatabase,
+				Table:    subadminTable,
+			},
+			Record: &storepb.Record{
+				Key:   key,
+				Value: []byte{},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o Ocs) removeSubadmin(ctx context.Context, userid, groupid string) error {
+	for _, key := range []string{subadminUserKey(userid, groupid), subadminGroupKey(groupid, userid)} {
+		_, err := o.getStoreService().Delete(ctx, &storepb.DeleteRequest{
+			Options: &storepb.DeleteOptions{
+				Database: subadminDatabase,
+				Table:    subadminTable,
+			},
+			Key: key,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeUserSubadmins drops all subadmin assignments of a deleted user
+func (o Ocs) removeUserSubadmins(ctx context.Context, userid string) error {
+	groups, err := o.listSubadminGroups(ctx, userid)
+	if err != nil {
+		return err
+	}
+	for _, groupid := range groups {
+		if err := o.removeSubadmin(ctx, userid, groupid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeGroupSubadmins drops all subadmin assignments of a deleted group
+func (o Ocs) removeGroupSubadmins(ctx context.Context, groupid string) error {
+	users, err := o.listGroupSubadmins(ctx, groupid)
+	if err != nil {
+		return err
+	}
+	for _, userid := range users {
+		if err := o.removeSubadmin(ctx, userid, groupid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupIDParam returns the groupid parameter of a group membership or subadmin request.
+// It is sent in the body, some clients send it in the query of DELETE requests.
+func groupIDParam(r *http.Request) string {
+	return r.FormValue("groupid")
+}
@@ -1,21 +1,25 @@
 package svc
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/cs3org/reva/pkg/user"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
-	"github.com/micro/go-micro/v2/client/grpc"
 	merrors "github.com/micro/go-micro/v2/errors"
 	accounts "github.com/owncloud/ocis-accounts/pkg/proto/v0"
+	ocsm "github.com/owncloud/ocis-ocs/pkg/middleware"
 	"github.com/owncloud/ocis-ocs/pkg/service/v0/data"
 	"github.com/owncloud/ocis-ocs/pkg/service/v0/response"
 	storepb "github.com/owncloud/ocis-store/pkg/proto/v0"
@@ -23,7 +27,6 @@ import (
 
 // GetUser returns the currently logged in user
 func (o Ocs) GetUser(w http.ResponseWriter, r *http.Request) {
-	// TODO this endpoint needs authentication using the roles and permissions
 	userid := chi.URLParam(r, "userid")
 
 	if userid == "" {
@@ -56,6 +59,34 @@ func (o Ocs) GetUser(w http.ResponseWriter, r *http.Request) {
 	}
 	o.logger.Debug().Interface("account", account).Msg("got user")
 
+	u := userData(account)
+	u.Quota = o.getQuota(r.Context(), account)
+	render.Render(w, r, response.DataRender(u))
+}
+
+// AddUser creates a new user account
+func (o Ocs) AddUser(w http.ResponseWriter, r *http.Request) {
+	account, oerr := o.createUser(r.Context(), &userDefinition{
+		UserID:      r.PostFormValue("userid"),
+		Password:    r.PostFormValue("password"),
+		Username:    r.PostFormValue("username"),
+		DisplayName: r.PostFormValue("displayname"),
+		Email:       r.PostFormValue("email"),
+		UIDNumber:   numberValue(r.PostFormValue("uidnumber")),
+		GIDNumber:   numberValue(r.PostFormValue("gidnumber")),
+		Groups:      r.PostForm["groups[]"],
+	})
+	if oerr != nil {
+		render.Render(w, r, response.ErrRender(oerr.code, oerr.message))
+		return
+	}
+
+	// remove password from log if it is set
+	if account.PasswordProfile != nil {
+		account.PasswordProfile.Password = ""
+	}
+	o.logger.Debug().Interface("account", account).Msg("added user")
+
 	// mimic the oc10 bool as string for the user enabled property
 	var enabled string
 	if account.AccountEnabled {
@@ -63,56 +94,109 @@ func (o Ocs) GetUser(w http.ResponseWriter, r *http.Request) {
 	} else {
 		enabled = "false"
 	}
-
 	render.Render(w, r, response.DataRender(&data.User{
-		UserID:            account.Id, // TODO userid vs username! implications for clients if we return the userid here? -> implement graph ASAP?
+		UserID:            account.Id,
 		Username:          account.PreferredName,
 		DisplayName:       account.DisplayName,
 		LegacyDisplayName: account.DisplayName,
 		Email:             account.Mail,
 		UIDNumber:         account.UidNumber,
-		GIDNumber:         account.GidNumber,
+		GIDNumber:         account.UidNumber,
 		Enabled:           enabled,
-		// FIXME onlyfor users/{userid} endpoint (not /user)
-		// TODO query storage registry for free space? of home storage, maybe...
-		Quota: &data.Quota{
-			Free:       2840756224000,
-			Used:       5059416668,
-			Total:      2845815640668,
-			Relative:   0.18,
-			Definition: "default",
-		},
+		Groups:            groupIDs(account),
 	}))
 }
 
-// AddUser creates a new user account
-func (o Ocs) AddUser(w http.ResponseWriter, r *http.Request) {
-	// TODO this endpoint needs authentication using the roles and permissions
-	userid := r.PostFormValue("userid")
-	password := r.PostFormValue("password")
-	username := r.PostFormValue("username")
-	displayname := r.PostFormValue("displayname")
-	email := r.PostFormValue("email")
-	uid := r.PostFormValue("uidnumber")
-	gid := r.PostFormValue("gidnumber")
+// groupIDs returns the ids of the groups of the account
+func groupIDs(account *accounts.Account) []string {
+	groups := make([]string, 0, len(account.MemberOf))
+	for _, g := range account.MemberOf {
+		groups = append(groups, g.Id)
+	}
+	return groups
+}
+
+// userDefinition holds the fields of a user to create
+type userDefinition struct {
+	UserID      string      `json:"userid"`
+	Username    string      `json:"username"`
+	Password    string      `json:"password"`
+	DisplayName string      `json:"displayname"`
+	Email       string      `json:"email"`
+	UIDNumber   numberValue `json:"uidnumber"`
+	GIDNumber   numberValue `json:"gidnumber"`
+	Groups      []string    `json:"groups"`
+}
 
+// numberValue accepts json numbers and strings, it is validated like the form values of AddUser
+type numberValue string
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (n *numberValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*n = numberValue(s)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return err
+	}
+	*n = numberValue(num)
+	return nil
+}
+
+// ocsError holds the ocs status code and message of a failed operation
+type ocsError struct {
+	code    int
+	message string
+}
+
+// createUser creates an account and adds it to the groups of the definition.
+// Subadmins can only create users in the groups they administer.
+func (o Ocs) createUser(ctx context.Context, u *userDefinition) (*accounts.Account, *ocsError) {
 	var uidNumber, gidNumber int64
 	var err error
 
-	if uid != "" {
-		uidNumber, err = strconv.ParseInt(uid, 10, 64)
+	if u.UIDNumber != "" {
+		uidNumber, err = strconv.ParseInt(string(u.UIDNumber), 10, 64)
 		if err != nil {
-			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "Cannot use the uidnumber provided"))
-			o.logger.Error().Err(err).Str("userid", userid).Msg("Cannot use the uidnumber provided")
-			return
+			o.logger.Error().Err(err).Str("userid", u.UserID).Msg("Cannot use the uidnumber provided")
+			return nil, &ocsError{data.MetaBadRequest.StatusCode, "Cannot use the uidnumber provided"}
 		}
 	}
-	if gid != "" {
-		gidNumber, err = strconv.ParseInt(gid, 10, 64)
+	if u.GIDNumber != "" {
+		gidNumber, err = strconv.ParseInt(string(u.GIDNumber), 10, 64)
 		if err != nil {
-			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, "Cannot use the gidnumber provided"))
-			o.logger.Error().Err(err).Str("userid", userid).Msg("Cannot use the gidnumber provided")
-			return
+			o.logger.Error().Err(err).Str("userid", u.UserID).Msg("Cannot use the gidnumber provided")
+			return nil, &ocsError{data.MetaBadRequest.StatusCode, "Cannot use the gidnumber provided"}
+		}
+	}
+
+	if msg := o.passwords.violation(u.Password, u.Username); msg != "" {
+		return nil, &ocsError{data.MetaForbidden.StatusCode, msg}
+	}
+
+	// subadmins can only create users in the groups they administer
+	if scope, ok := subadminScope(ctx); ok {
+		if len(u.Groups) == 0 {
+			return nil, &ocsError{106, "no group specified (required for subadmins)"}
+		}
+		for _, groupid := range u.Groups {
+			if !scope[groupid] {
+				return nil, &ocsError{105, "insufficient privileges for group " + groupid}
+			}
+		}
+	}
+
+	// check the groups before creating the account, so that it is not created for a typo
+	for _, groupid := range u.Groups {
+		if _, err := o.getGroupsService().GetGroup(ctx, &accounts.GetGroupRequest{Id: groupid}); err != nil {
+			if merrors.FromError(err).Code == http.StatusNotFound {
+				return nil, &ocsError{104, "group " + groupid + " does not exist"}
+			}
+			o.logger.Error().Err(err).Str("groupid", groupid).Msg("could not get group")
+			return nil, &ocsError{data.MetaServerError.StatusCode, err.Error()}
 		}
 	}
 
@@ -129,14 +213,14 @@ func (o Ocs) AddUser(w http.ResponseWriter, r *http.Request) {
 	*/
 
 	newAccount := &accounts.Account{
-		DisplayName:              displayname,
-		PreferredName:            username,
-		OnPremisesSamAccountName: username,
+		DisplayName:              u.DisplayName,
+		PreferredName:            u.Username,
+		OnPremisesSamAccountName: u.Username,
 		PasswordProfile: &accounts.PasswordProfile{
-			Password: password,
+			Password: u.Password,
 		},
-		Id:             userid,
-		Mail:           email,
+		Id:             u.UserID,
+		Mail:           u.Email,
 		AccountEnabled: true,
 	}
 
@@ -148,49 +232,50 @@ func (o Ocs) AddUser(w http.ResponseWriter, r *http.Request) {
 		newAccount.GidNumber = gidNumber
 	}
 
-	account, err := o.getAccountService().CreateAccount(r.Context(), &accounts.CreateAccountRequest{
+	account, err := o.getAccountService().CreateAccount(ctx, &accounts.CreateAccountRequest{
 		Account: newAccount,
 	})
 	if err != nil {
+		o.logger.Error().Err(err).Str("userid", u.UserID).Msg("could not add user")
 		merr := merrors.FromError(err)
 		if merr.Code == http.StatusBadRequest {
-			render.Render(w, r, response.ErrRender(data.MetaBadRequest.StatusCode, merr.Detail))
-		} else {
-			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+			return nil, &ocsError{data.MetaBadRequest.StatusCode, merr.Detail}
 		}
-		o.logger.Error().Err(err).Str("userid", userid).Msg("could not add user")
 		// TODO check error if account already existed
-		return
+		return nil, &ocsError{data.MetaServerError.StatusCode, err.Error()}
 	}
 
-	// remove password from log if it is set
-	if account.PasswordProfile != nil {
-		account.PasswordProfile.Password = ""
+	if len(u.Groups) == 0 {
+		return account, nil
 	}
-	o.logger.Debug().Interface("account", account).Msg("added user")
 
-	// mimic the oc10 bool as string for the user enabled property
-	var enabled string
-	if account.AccountEnabled {
-		enabled = "true"
-	} else {
-		enabled = "false"
+	for _, groupid := range u.Groups {
+		_, err := o.getGroupsService().AddMember(ctx, &accounts.AddMemberRequest{
+			AccountId: account.Id,
+			GroupId:   groupid,
+		})
+		if err != nil {
+			o.logger.Error().Err(err).Str("userid", account.Id).Str("groupid", groupid).Msg("could not add new user to group")
+			// do not leave a user without the requested groups behind
+			if _, err := o.getAccountService().DeleteAccount(ctx, &accounts.DeleteAccountRequest{Id: account.Id}); err != nil {
+				o.logger.Error().Err(err).Str("userid", account.Id).Msg("could not delete user after adding it to a group failed")
+			}
+			return nil, &ocsError{data.MetaServerError.StatusCode, "could not add user to group " + groupid}
+		}
 	}
-	render.Render(w, r, response.DataRender(&data.User{
-		UserID:            account.Id,
-		Username:          account.PreferredName,
-		DisplayName:       account.DisplayName,
-		LegacyDisplayName: account.DisplayName,
-		Email:             account.Mail,
-		UIDNumber:         account.UidNumber,
-		GIDNumber:         account.UidNumber,
-		Enabled:           enabled,
-	}))
+
+	// read the account again to report the effective groups
+	updated, err := o.getAccountService().GetAccount(ctx, &accounts.GetAccountRequest{Id: account.Id})
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", account.Id).Msg("could not get groups of new user")
+		return account, nil
+	}
+	return updated, nil
 }
 
-// EditUser creates a new user account
+// EditUser changes the email, username, password, display name or quota of a user. Users who are not admins
+// cannot change their own username and change their own password with EditCurrentUser.
 func (o Ocs) EditUser(w http.ResponseWriter, r *http.Request) {
-	// TODO this endpoint needs authentication
 	req := accounts.UpdateAccountRequest{
 		Account: &accounts.Account{
 			Id: chi.URLParam(r, "userid"),
@@ -204,10 +289,20 @@ func (o Ocs) EditUser(w http.ResponseWriter, r *http.Request) {
 		req.Account.Mail = value
 		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"Mail"}}
 	case "username":
+		if !o.allowSelfEdit(w, r, req.Account.Id, "Forbidden") {
+			return
+		}
 		req.Account.PreferredName = value
 		req.Account.OnPremisesSamAccountName = value
 		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"PreferredName", "OnPremisesSamAccountName"}}
 	case "password":
+		// changing the own password requires the current password
+		if !o.allowSelfEdit(w, r, req.Account.Id, "Use PUT /cloud/user with the current password to change your own password") {
+			return
+		}
+		if !o.checkPassword(w, r, req.Account.Id, value) {
+			return
+		}
 		req.Account.PasswordProfile = &accounts.PasswordProfile{
 			Password: value,
 		}
@@ -215,13 +310,104 @@ func (o Ocs) EditUser(w http.ResponseWriter, r *http.Request) {
 	case "displayname", "display":
 		req.Account.DisplayName = value
 		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"DisplayName"}}
+	case "quota":
+		// the quota is not part of the account, it is kept by ocs and handed to the storage
+		o.editQuota(w, r, req.Account.Id, value)
+		return
 	default:
 		// https://github.com/owncloud/core/blob/24b7fa1d2604a208582055309a5638dbd9bda1d1/apps/provisioning_api/lib/Users.php#L321
 		render.Render(w, r, response.ErrRender(103, "unknown key '"+key+"'"))
 		return
 	}
 
-	account, err := o.getAccountService().UpdateAccount(r.Context(), &req)
+	o.updateAccount(w, r, &req)
+}
+
+// EditCurrentUser lets the current user change their own display name, email and password.
+// Changing the password requires the current password in the currentpassword parameter.
+func (o Ocs) EditCurrentUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := currentUser(r.Context())
+	if !ok {
+		render.Render(w, r, response.ErrRender(data.MetaUnauthorized.StatusCode, "missing user in context"))
+		return
+	}
+	req := accounts.UpdateAccountRequest{
+		Account: &accounts.Account{
+			Id: u.Id.OpaqueId,
+		},
+	}
+	key := r.PostFormValue("key")
+	value := r.PostFormValue("value")
+
+	switch key {
+	case "email":
+		req.Account.Mail = value
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"Mail"}}
+	case "displayname", "display":
+		req.Account.DisplayName = value
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"DisplayName"}}
+	case "password":
+		account, err := o.getAccountService().GetAccount(r.Context(), &accounts.GetAccountRequest{Id: u.Id.OpaqueId})
+		if err != nil {
+			o.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not get current user")
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+			return
+		}
+		if _, err := o.verifyPassword(r.Context(), account.OnPremisesSamAccountName, r.PostFormValue("currentpassword")); err != nil {
+			if err == errInvalidCredentials {
+				render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "The current password is not correct"))
+				return
+			}
+			o.logger.Error().Err(err).Str("userid", u.Id.OpaqueId).Msg("could not verify current password")
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not verify current password"))
+			return
+		}
+		if msg := o.passwords.violation(value, account.OnPremisesSamAccountName); msg != "" {
+			render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, msg))
+			return
+		}
+		req.Account.PasswordProfile = &accounts.PasswordProfile{
+			Password: value,
+		}
+		req.UpdateMask = &fieldmaskpb.FieldMask{Paths: []string{"PasswordProfile.Password"}}
+	case "username", "quota", "enabled":
+		o.logger.Debug().Str("userid", u.Id.OpaqueId).Str("key", key).Msg("users cannot change admin only fields of their account")
+		render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, "Forbidden"))
+		return
+	default:
+		render.Render(w, r, response.ErrRender(103, "unknown key '"+key+"'"))
+		return
+	}
+
+	o.updateAccount(w, r, &req)
+}
+
+// checkPassword renders an error and returns false if the new password of the user violates the password policy
+func (o Ocs) checkPassword(w http.ResponseWriter, r *http.Request, userid, password string) bool {
+	var username string
+	if o.passwords.DisallowUsername {
+		account, err := o.getAccountService().GetAccount(r.Context(), &accounts.GetAccountRequest{Id: userid})
+		if err != nil {
+			if merrors.FromError(err).Code == http.StatusNotFound {
+				render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested user could not be found"))
+			} else {
+				render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+			}
+			o.logger.Error().Err(err).Str("userid", userid).Msg("could not get user")
+			return false
+		}
+		username = account.OnPremisesSamAccountName
+	}
+	if msg := o.passwords.violation(password, username); msg != "" {
+		render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, msg))
+		return false
+	}
+	return true
+}
+
+// updateAccount updates the account and renders the result
+func (o Ocs) updateAccount(w http.ResponseWriter, r *http.Request, req *accounts.UpdateAccountRequest) {
+	account, err := o.getAccountService().UpdateAccount(r.Context(), req)
 	if err != nil {
 		merr := merrors.FromError(err)
 		switch merr.Code {
@@ -245,6 +431,60 @@ func (o Ocs) EditUser(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, response.DataRender(struct{}{}))
 }
 
+// allowSelfEdit renders an error with the message and returns false if a user who is not an admin edits an
+// admin only field of their own account
+func (o Ocs) allowSelfEdit(w http.ResponseWriter, r *http.Request, userid, message string) bool {
+	u, ok := currentUser(r.Context())
+	if !ok || !isSelf(u, userid) {
+		return true
+	}
+	admin, err := o.isAdmin(r.Context(), u)
+	if err != nil {
+		o.logger.Error().Err(err).Str("userid", userid).Msg("could not get role assignments")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not check permissions"))
+		return false
+	}
+	if !admin {
+		o.logger.Debug().Str("userid", userid).Msg("users cannot change admin only fields of their account")
+		render.Render(w, r, response.ErrRender(data.MetaForbidden.StatusCode, message))
+		return false
+	}
+	return true
+}
+
+// editQuota sets the quota of a user. Only admins may change their own quota.
+func (o Ocs) editQuota(w http.ResponseWriter, r *http.Request, userid, value string) {
+	if !o.allowSelfEdit(w, r, userid, "Forbidden") {
+		return
+	}
+
+	definition, err := parseQuota(value)
+	if err != nil {
+		render.Render(w, r, response.ErrRender(103, err.Error()))
+		return
+	}
+
+	account, err := o.getAccountService().GetAccount(r.Context(), &accounts.GetAccountRequest{Id: userid})
+	if err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested user could not be found"))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("userid", userid).Msg("could not get user")
+		return
+	}
+
+	if err := o.setQuota(r.Context(), account, definition); err != nil {
+		o.logger.Error().Err(err).Str("userid", userid).Str("quota", definition).Msg("could not set quota")
+		render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, "could not set quota"))
+		return
+	}
+
+	o.logger.Debug().Str("userid", userid).Str("quota", definition).Msg("updated quota")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
 // DeleteUser deletes a user
 func (o Ocs) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	req := accounts.DeleteAccountRequest{
@@ -263,10 +503,54 @@ func (o Ocs) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := o.removeUserSubadmins(r.Context(), req.Id); err != nil {
+		o.logger.Error().Err(err).Str("userid", req.Id).Msg("could not remove subadmin assignments of deleted user")
+	}
+
 	o.logger.Debug().Str("userid", req.Id).Msg("deleted user")
 	render.Render(w, r, response.DataRender(struct{}{}))
 }
 
+// EnableUser enables a user
+func (o Ocs) EnableUser(w http.ResponseWriter, r *http.Request) {
+	o.setAccountEnabled(w, r, true)
+}
+
+// DisableUser disables a user, the access tokens of disabled users are rejected
+func (o Ocs) DisableUser(w http.ResponseWriter, r *http.Request) {
+	o.setAccountEnabled(w, r, false)
+}
+
+func (o Ocs) setAccountEnabled(w http.ResponseWriter, r *http.Request, enabled bool) {
+	userid := chi.URLParam(r, "userid")
+
+	// admins would lock themselves out
+	if u, ok := currentUser(r.Context()); ok && !enabled && isSelf(u, userid) {
+		render.Render(w, r, response.ErrRender(data.MetaFailure.StatusCode, "cannot disable yourself"))
+		return
+	}
+
+	_, err := o.getAccountService().UpdateAccount(r.Context(), &accounts.UpdateAccountRequest{
+		Account: &accounts.Account{
+			Id:             userid,
+			AccountEnabled: enabled,
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"AccountEnabled"}},
+	})
+	if err != nil {
+		if merrors.FromError(err).Code == http.StatusNotFound {
+			render.Render(w, r, response.ErrRender(data.MetaNotFound.StatusCode, "The requested user could not be found"))
+		} else {
+			render.Render(w, r, response.ErrRender(data.MetaServerError.StatusCode, err.Error()))
+		}
+		o.logger.Error().Err(err).Str("userid", userid).Bool("enabled", enabled).Msg("could not update user")
+		return
+	}
+
+	o.logger.Debug().Str("userid", userid).Bool("enabled", enabled).Msg("updated user")
+	render.Render(w, r, response.DataRender(struct{}{}))
+}
+
 // GetSigningKey returns the signing key for the current user. It will create it on the fly if it does not exist
 // The signing key is part of the user settings and is used by the proxy to authenticate requests
 // Currently, the username is used as the OC-Credential
@@ -281,7 +565,7 @@ func (o Ocs) GetSigningKey(w http.ResponseWriter, r *http.Request) {
 	// use the user's UUID
 	userID := u.Id.OpaqueId
 
-	c := storepb.NewStoreService("com.owncloud.api.store", grpc.NewClient())
+	c := o.getStoreService()
 	res, err := c.Read(r.Context(), &storepb.ReadRequest{
 		Options: &storepb.ReadOptions{
 			Database: "proxy",
@@ -339,16 +623,18 @@ func (o Ocs) GetSigningKey(w http.ResponseWriter, r *http.Request) {
 	}))
 }
 
-// ListUsers lists the users
+// ListUsers lists the users. The search matches the beginning of the id, username, display name and email,
+// ignoring case. With details=true full user records are returned instead of ids.
 func (o Ocs) ListUsers(w http.ResponseWriter, r *http.Request) {
-	search := r.URL.Query().Get("search")
-	query := ""
-	if search != "" {
-		query = fmt.Sprintf("id eq '%s' or on_premises_sam_account_name eq '%s'", escapeValue(search), escapeValue(search))
+	p, ok := paginationParams(w, r)
+	if !ok {
+		return
 	}
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
+	details := r.URL.Query().Get("details") == "true"
 
 	res, err := o.getAccountService().ListAccounts(r.Context(), &accounts.ListAccountsRequest{
-		Query: query,
+		Query: searchQuery(search, "id", "on_premises_sam_account_name", "preferred_name", "display_name", "mail"),
 	})
 	if err != nil {
 		o.logger.Err(err).Msg("could not list users")
@@ -356,15 +642,115 @@ func (o Ocs) ListUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users := []string{}
-	for i := range res.Accounts {
-		users = append(users, res.Accounts[i].Id)
+	// subadmins only see the users of the groups they administer
+	scope, scoped := subadminScope(r.Context())
+
+	matches := make([]*accounts.Account, 0, len(res.Accounts))
+	for _, a := range res.Accounts {
+		if scoped && !memberOfAny(a, scope) {
+			continue
+		}
+		matches = append(matches, a)
 	}
 
-	render.Render(w, r, response.DataRender(&data.Users{Users: users}))
+	if !details {
+		users := make([]string, 0, len(matches))
+		for _, a := range matches {
+			users = append(users, a.Id)
+		}
+		p.render(w, r, &data.Users{Users: p.page(users)}, len(users))
+		return
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Id < matches[j].Id
+	})
+	start, end := p.bounds(len(matches))
+	users := make([]*data.User, 0, end-start)
+	for _, a := range matches[start:end] {
+		users = append(users, userData(a))
+	}
+	p.render(w, r, &data.UserDetails{Users: users}, len(matches))
 }
 
-// escapeValue escapes all special characters in the value
+// userData converts an account to the oc10 user representation, without the quota
+func userData(account *accounts.Account) *data.User {
+	// mimic the oc10 bool as string for the user enabled property
+	var enabled string
+	if account.AccountEnabled {
+		enabled = "true"
+	} else {
+		enabled = "false"
+	}
+
+	return &data.User{
+		UserID:            account.Id, // TODO userid vs username! implications for clients if we return the userid here? -> implement graph ASAP?
+		Username:          account.PreferredName,
+		DisplayName:       account.DisplayName,
+		LegacyDisplayName: account.DisplayName,
+		Email:             account.Mail,
+		UIDNumber:         account.UidNumber,
+		GIDNumber:         account.GidNumber,
+		Enabled:           enabled,
+	}
+}
+
+// lookupAccount finds an account by its id or, as a fallback, by its username
+func (o Ocs) lookupAccount(ctx context.Context, idOrName string) (*accounts.Account, error) {
+	account, err := o.getAccountService().GetAccount(ctx, &accounts.GetAccountRequest{Id: idOrName})
+	if err == nil {
+		return account, nil
+	}
+	if merrors.FromError(err).Code != http.StatusNotFound {
+		return nil, err
+	}
+
+	res, err := o.getAccountService().ListAccounts(ctx, &accounts.ListAccountsRequest{
+		Query: fmt.Sprintf("on_premises_sam_account_name eq '%s'", escapeValue(idOrName)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Accounts) != 1 {
+		return nil, merrors.NotFound("com.owncloud.api.ocs", "account %s not found", idOrName)
+	}
+	return res.Accounts[0], nil
+}
+
+// errInvalidCredentials is returned when a username and password do not match an account.
+// The basic auth middleware uses it to tell wrong credentials from unavailable accounts services.
+var errInvalidCredentials = ocsm.ErrInvalidCredentials
+
+// verifyPassword returns the account with the username if the password is correct
+func (o Ocs) verifyPassword(ctx context.Context, username, password string) (*accounts.Account, error) {
+	if username == "" || password == "" {
+		return nil, errInvalidCredentials
+	}
+	res, err := o.getAccountService().ListAccounts(ctx, &accounts.ListAccountsRequest{
+		Query: fmt.Sprintf("on_premises_sam_account_name eq '%s' and password eq '%s'", escapeValue(username), quoteValue(password)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Accounts) != 1 {
+		return nil, errInvalidCredentials
+	}
+	return res.Accounts[0], nil
+}
+
+// escapeValue escapes an id or name for a string literal in an accounts query. Control characters, which
+// cannot be part of ids or names, are dropped and quotes are doubled.
 func escapeValue(value string) string {
+	return quoteValue(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value))
+}
+
+// quoteValue escapes a value for a string literal in an accounts query by doubling quotes. Other characters
+// are kept, so it has to be used for passwords.
+func quoteValue(value string) string {
 	return strings.ReplaceAll(value, "'", "''")
 }